@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"os/exec"
+	"strings"
 
 	"github.com/ifeitao/hugo-revise/internal/config"
 	"github.com/ifeitao/hugo-revise/internal/revise"
@@ -17,48 +19,170 @@ func main() {
 		Short: "Versioned revision workflow for Hugo content",
 		Args:  cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if len(args) == 0 {
+			opts := reviseOptions(cmd)
+			if len(args) == 0 && opts.URL == "" && opts.Title == "" {
 				return cmd.Help()
 			}
+			return runRevise(cmd, args, opts)
+		},
+	}
+
+	root.PersistentFlags().StringP("config", "c", ".hugo-reviserc.toml", "Path to config file")
+	addReviseFlags(root)
+
+	reviseCmd := &cobra.Command{
+		Use:   "revise [PATH_PREFIX]",
+		Short: "Create a new revision for content",
+		Long: `Create a new revision for content.
+
+The page is given by its path on disk, its path in the content tree
+(posts/my-post), or with --url or --title.
+
+The revision note, why the page was revised, is given with -m; without
+it $VISUAL or $EDITOR is opened when running in a terminal.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runRevise(cmd, args, reviseOptions(cmd))
+		},
+	}
+
+	addReviseFlags(reviseCmd)
+
+	undoCmd := &cobra.Command{
+		Use:   "undo",
+		Short: "Undo last reviser operation",
+		RunE: func(cmd *cobra.Command, args []string) error {
 			cfgPath, _ := cmd.Flags().GetString("config")
 			cfg, err := config.Load(cfgPath)
 			if err != nil {
 				return err
 			}
-			return revise.Run(cfg, args[0])
+			return undo.Run(cfg)
 		},
 	}
 
-	root.PersistentFlags().StringP("config", "c", ".hugo-reviserc.toml", "Path to config file")
+	relabelCmd := &cobra.Command{
+		Use:   "relabel PAGE OLD NEW",
+		Short: "Rename a version of a page",
+		Long: `Rename the version labelled OLD to NEW, in every language of the page.
 
-	reviseCmd := &cobra.Command{
-		Use:   "revise [PATH_PREFIX]",
-		Short: "Create a new revision for content",
-		Args:  cobra.ExactArgs(1),
+The archive file or directory is moved, its url follows the new label,
+and revisions_history, notes, authors and the structured revisions list
+are rewritten in the page and all its archives. With --alias the old URL
+of the archive is added to its aliases so links to it keep working.`,
+		Args: cobra.ExactArgs(3),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cfgPath, _ := cmd.Flags().GetString("config")
 			cfg, err := config.Load(cfgPath)
 			if err != nil {
 				return err
 			}
-			return revise.Run(cfg, args[0])
+			alias, _ := cmd.Flags().GetBool("alias")
+			return revise.Relabel(cfg, args[0], args[1], args[2], revise.RelabelOptions{Alias: alias})
 		},
 	}
+	relabelCmd.Flags().Bool("alias", false, "Add the archive's old URL to its aliases")
 
-	undoCmd := &cobra.Command{
-		Use:   "undo",
-		Short: "Undo last reviser operation",
+	updateCmd := &cobra.Command{
+		Use:   "update [PATH_PREFIX]",
+		Short: "Log a minor change without archiving",
+		Long: `Log a minor change to the current version of a page.
+
+lastmod is refreshed and an entry with the kind of change, its note, the
+date and the author is added to the changelog list in the page's front
+matter. Nothing is archived.
+
+The note is given with -m; without it $VISUAL or $EDITOR is opened when
+running in a terminal.`,
+		Args: cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cfgPath, _ := cmd.Flags().GetString("config")
 			cfg, err := config.Load(cfgPath)
 			if err != nil {
 				return err
 			}
-			return undo.Run(cfg)
+			url, _ := cmd.Flags().GetString("url")
+			title, _ := cmd.Flags().GetString("title")
+			kind, _ := cmd.Flags().GetString("kind")
+			message, _ := cmd.Flags().GetString("message")
+			author, _ := cmd.Flags().GetString("author")
+			if cmd.Flags().Changed("message") {
+				return revise.Update(cfg, pathArg(args), revise.UpdateOptions{URL: url, Title: title, Kind: kind, Message: message, Author: author})
+			}
+			if message, err = editMessage("What changed?"); err != nil {
+				return err
+			}
+			return keepMessage(message, revise.Update(cfg, pathArg(args), revise.UpdateOptions{URL: url, Title: title, Kind: kind, Message: message, Author: author}))
 		},
 	}
+	updateCmd.Flags().String("url", "", "Select the page by its public URL (e.g. /posts/foo/)")
+	updateCmd.Flags().String("title", "", "Select the page by its title")
+	updateCmd.MarkFlagsMutuallyExclusive("url", "title")
+	updateCmd.Flags().String("kind", "update", "Kind of change: correction, clarification or update")
+	updateCmd.Flags().StringP("message", "m", "", "What changed (default: ask in $EDITOR)")
+	updateCmd.Flags().String("author", "", "Who makes the update (default: $HUGO_REVISE_AUTHOR, then git user.name and user.email)")
+
+	manifestCmd := &cobra.Command{
+		Use:   "manifest PAGE",
+		Short: "Rebuild the manifest of a page's archives from disk",
+		Long: `Rebuild manifest.json, the list of a page's archived versions kept in
+its revisions directory, from the archives there. The directory's hidden
+_index, which keeps Hugo from publishing the manifest, is written when it
+is missing.
+
+Content files named after their label and directories holding an index
+file are versions; anything else in the directory is left out. Use it
+after moving archives by hand or to adopt a revisions directory made
+before manifests were kept.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfgPath, _ := cmd.Flags().GetString("config")
+			cfg, err := config.Load(cfgPath)
+			if err != nil {
+				return err
+			}
+			versions, err := revise.RebuildManifest(cfg, args[0])
+			if err != nil {
+				return err
+			}
+			for _, v := range versions {
+				fmt.Printf("%s\t%s\n", v.Label, v.Path)
+			}
+			return nil
+		},
+	}
+
+	gcCmd := &cobra.Command{
+		Use:   "gc",
+		Short: "Remove stored resources no archive uses",
+		Long: `Remove the files of the content-addressed store (storage.dedupe = "store")
+that no archive links to any more, as after undoing revisions or deleting
+archives by hand. Run it in the Hugo project root.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+			g, err := revise.CollectGarbage(dryRun)
+			if err != nil {
+				return err
+			}
+			for _, path := range g.Objects {
+				fmt.Println(path)
+			}
+			verb := "Removed"
+			if dryRun {
+				verb = "Would remove"
+			}
+			fmt.Printf("%s %d unused objects, %d bytes\n", verb, len(g.Objects), g.Bytes)
+			return nil
+		},
+	}
+	gcCmd.Flags().Bool("dry-run", false, "List the unused objects without removing them")
 
 	root.AddCommand(reviseCmd)
+	root.AddCommand(updateCmd)
+	root.AddCommand(relabelCmd)
+	root.AddCommand(manifestCmd)
+	root.AddCommand(gcCmd)
 	root.AddCommand(undoCmd)
 
 	if err := root.Execute(); err != nil {
@@ -66,3 +190,143 @@ func main() {
 		log.Fatal(err)
 	}
 }
+
+// addReviseFlags registers the flags shared by the root and revise commands.
+func addReviseFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("all-languages", false, "Revise every translation of the page, giving the new versions one label")
+	cmd.Flags().String("url", "", "Select the page by its public URL (e.g. /posts/foo/)")
+	cmd.Flags().String("title", "", "Select the page by its title")
+	cmd.MarkFlagsMutuallyExclusive("url", "title")
+	cmd.Flags().String("bump", "", "Semver part to bump for the new version: major, minor or patch (default minor)")
+	cmd.Flags().String("label", "", "Label of the new version instead of the configured strategy's")
+	cmd.Flags().String("archive-label", "", "Label of the archived version instead of the configured strategy's")
+	cmd.Flags().String("date", "", "Date of the revision instead of now (e.g. 2023-05-01 or 2023-05-01T10:00:00+08:00)")
+	cmd.Flags().StringP("message", "m", "", "Revision note: why the page was revised (default: ask in $EDITOR)")
+	cmd.Flags().String("author", "", "Who makes the revision (default: $HUGO_REVISE_AUTHOR, then git user.name and user.email)")
+	cmd.Flags().Bool("force", false, "Archive even when the page hardly changed since the latest archive")
+	cmd.Flags().String("on-collision", "", "When the new label is taken: error, suffix, time, amend or overwrite (default from versioning.on_collision)")
+}
+
+func reviseOptions(cmd *cobra.Command) revise.Options {
+	allLanguages, _ := cmd.Flags().GetBool("all-languages")
+	url, _ := cmd.Flags().GetString("url")
+	title, _ := cmd.Flags().GetString("title")
+	bump, _ := cmd.Flags().GetString("bump")
+	onCollision, _ := cmd.Flags().GetString("on-collision")
+	newLabel, _ := cmd.Flags().GetString("label")
+	archiveLabel, _ := cmd.Flags().GetString("archive-label")
+	date, _ := cmd.Flags().GetString("date")
+	message, _ := cmd.Flags().GetString("message")
+	author, _ := cmd.Flags().GetString("author")
+	force, _ := cmd.Flags().GetBool("force")
+	return revise.Options{
+		AllLanguages: allLanguages,
+		URL:          url,
+		Title:        title,
+		Bump:         bump,
+		OnCollision:  onCollision,
+		Label:        newLabel,
+		ArchiveLabel: archiveLabel,
+		Date:         date,
+		Message:      message,
+		Author:       author,
+		Force:        force,
+	}
+}
+
+// runRevise loads the config, asks for the revision note when -m was not
+// given, and revises the page.
+func runRevise(cmd *cobra.Command, args []string, opts revise.Options) error {
+	cfgPath, _ := cmd.Flags().GetString("config")
+	cfg, err := config.Load(cfgPath)
+	if err != nil {
+		return err
+	}
+	if cmd.Flags().Changed("message") {
+		return revise.Run(cfg, pathArg(args), opts)
+	}
+	if opts.Message, err = editMessage("Why was the page revised?"); err != nil {
+		return err
+	}
+	return keepMessage(opts.Message, revise.Run(cfg, pathArg(args), opts))
+}
+
+// keepMessage saves a note written in the editor to a file when the
+// command it was written for failed, so that it is not lost, and tells
+// where. It returns err.
+func keepMessage(message string, err error) error {
+	if err == nil || message == "" {
+		return err
+	}
+	f, ferr := os.CreateTemp("", "hugo-revise-note-*.txt")
+	if ferr != nil {
+		return err
+	}
+	_, ferr = f.WriteString(message + "\n")
+	if cerr := f.Close(); ferr == nil {
+		ferr = cerr
+	}
+	if ferr != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Your note was saved to %s; pass it again with -m \"$(cat %s)\"\n", f.Name(), f.Name())
+	return err
+}
+
+// editMessage asks question in $VISUAL or $EDITOR, the way git asks for a
+// commit message. Lines starting with # are dropped. Without a terminal or
+// an editor there is no note.
+func editMessage(question string) (string, error) {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" || !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+		return "", nil
+	}
+
+	f, err := os.CreateTemp("", "hugo-revise-*.txt")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString("\n# " + question + " Lines starting with # are ignored;\n# an empty note records none.\n")
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	parts := strings.Fields(editor)
+	c := exec.Command(parts[0], append(parts[1:], f.Name())...)
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := c.Run(); err != nil {
+		return "", fmt.Errorf("editor %s: %w", editor, err)
+	}
+	b, err := os.ReadFile(f.Name())
+	if err != nil {
+		return "", err
+	}
+	var lines []string
+	for _, l := range strings.Split(string(b), "\n") {
+		if !strings.HasPrefix(l, "#") {
+			lines = append(lines, strings.TrimRight(l, "\r"))
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n")), nil
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// pathArg returns the optional PATH_PREFIX argument.
+func pathArg(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	return args[0]
+}
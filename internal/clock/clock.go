@@ -0,0 +1,48 @@
+// Package clock reads the time an operation happens at, once, in the time
+// zone labels are made in.
+package clock
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// System is the clock read when SOURCE_DATE_EPOCH is not set.
+var System = time.Now
+
+// Location returns the time zone named by the first non-empty of names: an
+// IANA name such as Asia/Shanghai, "UTC", or "Local" for the machine's
+// zone, which is also used when all are empty.
+func Location(names ...string) (*time.Location, error) {
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		if strings.EqualFold(name, "local") {
+			return time.Local, nil
+		}
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			return nil, fmt.Errorf("time zone: %w", err)
+		}
+		return loc, nil
+	}
+	return time.Local, nil
+}
+
+// Now returns the current time in loc. SOURCE_DATE_EPOCH, seconds since
+// the Unix epoch as for reproducible builds, replaces the system clock when
+// it is set.
+func Now(loc *time.Location) (time.Time, error) {
+	if epoch := strings.TrimSpace(os.Getenv("SOURCE_DATE_EPOCH")); epoch != "" {
+		secs, err := strconv.ParseInt(epoch, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("SOURCE_DATE_EPOCH: %q is not a number of seconds", epoch)
+		}
+		return time.Unix(secs, 0).In(loc), nil
+	}
+	return System().In(loc), nil
+}
@@ -8,17 +8,44 @@ import (
 )
 
 type Versioning struct {
-	DateFormat string
+	DateFormat     string
+	Label          string  // label strategy: date, datetime, sequential, semver or template
+	DateTimeFormat string  // layout of datetime labels
+	Template       string  // text/template of template labels
+	OnCollision    string  // when the new label is taken: error, suffix, time, amend or overwrite
+	Timezone       string  // zone labels and dates are made in; defaults to the site's timeZone, then the machine's
+	MinChange      float64 // per cent of lines that must change since the latest archive
+	OnMinorChange  string  // below MinChange: warn or error
+
+	StructuredHistory bool // also write the structured revisions list
+}
+
+// Storage says where archived versions are kept.
+type Storage struct {
+	Layout string // sibling, central or mount
+	Dir    string // central: directory in each content root; mount: directory, relative to the site, mounted into content
+	Dedupe string // resources unchanged across archives: off, hardlink or store
 }
 
 type Config struct {
 	Versioning Versioning
+	Storage    Storage
 }
 
 func defaultConfig() Config {
 	return Config{
 		Versioning: Versioning{
-			DateFormat: "2006-01-02",
+			DateFormat:     "2006-01-02",
+			Label:          "date",
+			DateTimeFormat: "2006-01-02-1504",
+			OnCollision:    "error",
+			MinChange:      5,
+			OnMinorChange:  "warn",
+		},
+		Storage: Storage{
+			Layout: "sibling",
+			Dir:    "revisions",
+			Dedupe: "off",
 		},
 	}
 }
@@ -29,6 +56,14 @@ func Load(path string) (Config, error) {
 	v.SetConfigFile(path)
 	v.SetConfigType(detectType(path))
 	v.SetDefault("versioning.date_format", cfg.Versioning.DateFormat)
+	v.SetDefault("versioning.label", cfg.Versioning.Label)
+	v.SetDefault("versioning.datetime_format", cfg.Versioning.DateTimeFormat)
+	v.SetDefault("versioning.on_collision", cfg.Versioning.OnCollision)
+	v.SetDefault("versioning.min_change", cfg.Versioning.MinChange)
+	v.SetDefault("versioning.on_minor_change", cfg.Versioning.OnMinorChange)
+	v.SetDefault("storage.layout", cfg.Storage.Layout)
+	v.SetDefault("storage.dir", cfg.Storage.Dir)
+	v.SetDefault("storage.dedupe", cfg.Storage.Dedupe)
 
 	if _, err := os.Stat(path); err == nil {
 		if err := v.ReadInConfig(); err != nil {
@@ -37,6 +72,17 @@ func Load(path string) (Config, error) {
 	}
 
 	cfg.Versioning.DateFormat = v.GetString("versioning.date_format")
+	cfg.Versioning.Label = v.GetString("versioning.label")
+	cfg.Versioning.DateTimeFormat = v.GetString("versioning.datetime_format")
+	cfg.Versioning.Template = v.GetString("versioning.template")
+	cfg.Versioning.OnCollision = v.GetString("versioning.on_collision")
+	cfg.Versioning.Timezone = v.GetString("versioning.timezone")
+	cfg.Versioning.MinChange = v.GetFloat64("versioning.min_change")
+	cfg.Versioning.OnMinorChange = v.GetString("versioning.on_minor_change")
+	cfg.Storage.Layout = v.GetString("storage.layout")
+	cfg.Storage.Dir = v.GetString("storage.dir")
+	cfg.Storage.Dedupe = v.GetString("storage.dedupe")
+	cfg.Versioning.StructuredHistory = v.GetBool("versioning.structured_history")
 	return cfg, nil
 }
 
@@ -0,0 +1,331 @@
+// Package content locates Hugo content pages and their archived revisions.
+package content
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Extensions are the content file extensions Hugo renders, in the order
+// they are tried when a path is given without one.
+var Extensions = []string{
+	".md", ".markdown", ".mdown", ".mkdn", ".mkd",
+	".html", ".htm",
+	".adoc", ".asciidoc", ".ad",
+	".org",
+	".pdc", ".pandoc",
+	".rst",
+	".ipynb",
+}
+
+// IsContentFile reports whether name has one of the content Extensions.
+func IsContentFile(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, e := range Extensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// IsIndexFile reports whether name is a bundle index file in any language:
+// index.md, _index.adoc, index.zh.md and so on.
+func IsIndexFile(name string) bool {
+	if !IsContentFile(name) {
+		return false
+	}
+	base := strings.TrimSuffix(name, filepath.Ext(name))
+	for _, index := range []string{"index", "_index"} {
+		if base == index || strings.HasPrefix(base, index+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Page is a content page: a single file, a leaf bundle's index file or a
+// branch bundle's _index file, possibly one translation among several.
+type Page struct {
+	Source string // content file, e.g. content/posts/my-post.zh.adoc
+	Path   string // page path without language and extension: the bundle directory or the file's base path
+	Lang   string // language suffix of Source ("zh"), "" when it has none
+	Ext    string // extension of Source, e.g. ".adoc"
+	Bundle bool   // leaf or branch bundle
+	Branch bool   // branch bundle (_index.<ext>): a section, taxonomy or term page
+
+	langs  []string // the site's language codes, default first
+	layout Layout   // where archives are kept; nil is Sibling
+}
+
+// Layout decides where the archived versions of pages are kept.
+type Layout interface {
+	// RevisionsDir returns the directory holding the archives of p.
+	RevisionsDir(p Page) string
+}
+
+// Sibling keeps the archives of a page next to it, in <name>.revisions.
+var Sibling Layout = siblingLayout{}
+
+type siblingLayout struct{}
+
+func (siblingLayout) RevisionsDir(p Page) string {
+	return p.Path + ".revisions"
+}
+
+// WithLayout returns the page with its archives kept where l says. The
+// page's translations share the layout.
+func (p Page) WithLayout(l Layout) Page {
+	p.layout = l
+	return p
+}
+
+// nested reports whether the revisions directories of the page's layout
+// may hold those of other pages, as a central tree does for the pages of a
+// section.
+func (p Page) nested() bool {
+	return p.layout != nil && p.layout != Sibling
+}
+
+// Name is the page's file or bundle name without language and extension.
+func (p Page) Name() string {
+	return filepath.Base(p.Path)
+}
+
+// RevisionsDir is the directory holding the page's archived versions, as
+// its Layout places it. All translations of a page share it; each archive
+// carries the language suffix of the page it was taken from.
+func (p Page) RevisionsDir() string {
+	if p.layout == nil {
+		return Sibling.RevisionsDir(p)
+	}
+	return p.layout.RevisionsDir(p)
+}
+
+// suffix is the language and extension part of the page's file name.
+func (p Page) suffix() string {
+	if p.Lang == "" {
+		return p.Ext
+	}
+	return "." + p.Lang + p.Ext
+}
+
+// ArchiveFile is the content file of the archived version labelled label.
+// Archives keep the page's language suffix and extension, so Hugo links the
+// archived translations of one label to each other and renders them the
+// way it renders the page. Branch bundles are archived as leaf bundles
+// (index.<ext>) so that an archive is a single page, never a section with
+// children of its own.
+func (p Page) ArchiveFile(label string) string {
+	if p.Bundle {
+		return filepath.Join(p.RevisionsDir(), label, "index"+p.suffix())
+	}
+	return filepath.Join(p.RevisionsDir(), label+p.suffix())
+}
+
+// ArchivePath is what makes up the archived version labelled label: its
+// directory for bundles, its content file otherwise.
+func (p Page) ArchivePath(label string) string {
+	if p.Bundle {
+		return filepath.Join(p.RevisionsDir(), label)
+	}
+	return p.ArchiveFile(label)
+}
+
+// Resolve finds the page for a path given on the command line: a content
+// file, a bundle directory, or a file path without its extension. langs
+// are the site's language codes, default first. When a page exists in
+// several languages the file without a language suffix wins, then the
+// default language; otherwise the path is ambiguous.
+func Resolve(pathPrefix string, langs []string) (Page, error) {
+	path := filepath.Clean(pathPrefix)
+	if IsContentFile(path) {
+		if fi, err := os.Stat(path); err == nil && !fi.IsDir() {
+			return FromSource(path, langs), nil
+		}
+	}
+	var candidates []Page
+	if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+		candidates = variants(path, "index", langs)
+		if len(candidates) == 0 {
+			candidates = variants(path, "_index", langs)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = variants(filepath.Dir(path), filepath.Base(path), langs)
+	}
+	switch len(candidates) {
+	case 0:
+		return Page{}, fmt.Errorf("source not found: no index file in %s and no %s.<ext> content file (supported: %s)", path, path, strings.Join(Extensions, " "))
+	case 1:
+		return candidates[0], nil
+	}
+	if candidates[0].Lang == "" || (len(langs) > 0 && strings.EqualFold(candidates[0].Lang, langs[0])) {
+		return candidates[0], nil
+	}
+	var names []string
+	for _, c := range candidates {
+		names = append(names, c.Source)
+	}
+	return Page{}, fmt.Errorf("%s exists in several languages (%s); name one of them or use --all-languages", path, strings.Join(names, ", "))
+}
+
+// FromSource describes the page whose content file is sourceFile. langs
+// are the site's language codes; a suffix that is not one of them is part
+// of the name, as in Hugo.
+func FromSource(sourceFile string, langs []string) Page {
+	ext := filepath.Ext(sourceFile)
+	base, lang := splitLang(strings.TrimSuffix(filepath.Base(sourceFile), ext), langs)
+	p := Page{Source: sourceFile, Lang: lang, Ext: ext, langs: langs}
+	switch base {
+	case "index":
+		p.Bundle = true
+		p.Path = filepath.Dir(sourceFile)
+	case "_index":
+		p.Bundle, p.Branch = true, true
+		p.Path = filepath.Dir(sourceFile)
+	default:
+		p.Path = filepath.Join(filepath.Dir(sourceFile), base)
+	}
+	return p
+}
+
+// Translations returns the page in every language it exists in, the page
+// without a language suffix and the default language first.
+func (p Page) Translations() []Page {
+	if p.Bundle {
+		name := "index"
+		if p.Branch {
+			name = "_index"
+		}
+		return withLayout(variants(p.Path, name, p.langs), p.layout)
+	}
+	return withLayout(variants(filepath.Dir(p.Path), p.Name(), p.langs), p.layout)
+}
+
+func withLayout(pages []Page, l Layout) []Page {
+	for i := range pages {
+		pages[i].layout = l
+	}
+	return pages
+}
+
+// variants lists the content files dir/name[.lang]<ext> as pages, ordered
+// with the unsuffixed file first, then by the order of langs.
+func variants(dir, name string, langs []string) []Page {
+	entries, _ := os.ReadDir(dir)
+	var out []Page
+	for _, e := range entries {
+		if e.IsDir() || !IsContentFile(e.Name()) {
+			continue
+		}
+		base, _ := splitLang(strings.TrimSuffix(e.Name(), filepath.Ext(e.Name())), langs)
+		if base == name {
+			out = append(out, FromSource(filepath.Join(dir, e.Name()), langs))
+		}
+	}
+	rank := func(p Page) int {
+		if p.Lang == "" {
+			return -1
+		}
+		for i, l := range langs {
+			if strings.EqualFold(l, p.Lang) {
+				return i
+			}
+		}
+		return len(langs)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if rank(out[i]) != rank(out[j]) {
+			return rank(out[i]) < rank(out[j])
+		}
+		return extRank(out[i].Ext) < extRank(out[j].Ext)
+	})
+	return out
+}
+
+func extRank(ext string) int {
+	for i, e := range Extensions {
+		if strings.EqualFold(e, ext) {
+			return i
+		}
+	}
+	return len(Extensions)
+}
+
+// splitLang splits a trailing ".lang" off base when lang is one of langs.
+func splitLang(base string, langs []string) (string, string) {
+	i := strings.LastIndex(base, ".")
+	if i < 0 {
+		return base, ""
+	}
+	for _, l := range langs {
+		if strings.EqualFold(base[i+1:], l) {
+			return base[:i], base[i+1:]
+		}
+	}
+	return base, ""
+}
+
+// Archive is one archived version found in a revisions directory.
+type Archive struct {
+	Label string
+	File  string // content file; empty for a bundle directory without an index
+}
+
+// Archives lists the page's archived versions sorted by label, leaving out
+// the archives of its translations. They are the versions of the
+// directory's manifest whose files exist. Without a manifest the directory
+// is scanned: bundle archives are directories holding an index file;
+// single-file archives are content files named after their label.
+func (p Page) Archives() []Archive {
+	dir := p.RevisionsDir()
+	if m, ok, err := ReadManifest(p.ManifestPath()); ok && err == nil {
+		var out []Archive
+		for _, v := range m.Versions {
+			file := filepath.Join(dir, filepath.FromSlash(v.Path))
+			if !strings.EqualFold(v.Lang, p.Lang) || v.Path == "" {
+				continue
+			}
+			if _, err := os.Stat(file); err != nil {
+				continue
+			}
+			out = append(out, Archive{Label: v.Label, File: file})
+		}
+		sort.Slice(out, func(i, j int) bool { return out[i].Label < out[j].Label })
+		return out
+	}
+	entries, _ := os.ReadDir(dir)
+	var out []Archive
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() {
+			versions := variants(filepath.Join(dir, name), "index", p.langs)
+			a := Archive{Label: name}
+			for _, v := range versions {
+				if strings.EqualFold(v.Lang, p.Lang) {
+					a.File = v.Source
+					break
+				}
+			}
+			// A directory holding only other languages' archives is not ours,
+			// nor in a nested layout one without any index file: it holds
+			// the archives of a page further down.
+			if a.File == "" && (len(versions) > 0 || p.nested()) {
+				continue
+			}
+			out = append(out, a)
+		} else if IsContentFile(name) && !IsIndexFile(name) {
+			label, lang := splitLang(strings.TrimSuffix(name, filepath.Ext(name)), p.langs)
+			if !strings.EqualFold(lang, p.Lang) {
+				continue
+			}
+			out = append(out, Archive{Label: label, File: filepath.Join(dir, name)})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Label < out[j].Label })
+	return out
+}
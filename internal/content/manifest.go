@@ -0,0 +1,118 @@
+package content
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ManifestName is the file in a revisions directory that lists its
+// archived versions. The hidden _index written beside it keeps Hugo from
+// publishing it.
+const ManifestName = "manifest.json"
+
+// Manifest lists the archived versions kept in a revisions directory, in
+// every language. Once a directory has one, it is the record of which
+// versions exist: files and directories it does not list are not versions.
+type Manifest struct {
+	Versions []Version `json:"versions"`
+}
+
+// Version is one archived version of a page in one language.
+type Version struct {
+	Label   string `json:"label"`
+	Lang    string `json:"lang,omitempty"`    // language suffix of the archive, "" when it has none
+	Path    string `json:"path"`              // content file, relative to the revisions directory, with slashes
+	URL     string `json:"url,omitempty"`     // URL of the archive
+	Created string `json:"created,omitempty"` // when it was archived, RFC 3339
+	SHA256  string `json:"sha256,omitempty"`  // hex SHA-256 of the page body
+	Note    string `json:"note,omitempty"`    // revision note of the version
+	Author  string `json:"author,omitempty"`  // who made the version
+}
+
+// ManifestPath is the path of the manifest of the page's revisions
+// directory.
+func (p Page) ManifestPath() string {
+	return filepath.Join(p.RevisionsDir(), ManifestName)
+}
+
+// ReadManifest reads the manifest at path. ok is false when there is none.
+func ReadManifest(path string) (m Manifest, ok bool, err error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Manifest{}, false, nil
+	}
+	if err != nil {
+		return Manifest{}, false, err
+	}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return Manifest{}, false, err
+	}
+	return m, true, nil
+}
+
+// Write stores the manifest at path, its versions sorted by label and
+// language.
+func (m Manifest) Write(path string) error {
+	sort.SliceStable(m.Versions, func(i, j int) bool {
+		if m.Versions[i].Label != m.Versions[j].Label {
+			return m.Versions[i].Label < m.Versions[j].Label
+		}
+		return m.Versions[i].Lang < m.Versions[j].Lang
+	})
+	if m.Versions == nil {
+		m.Versions = []Version{}
+	}
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o644)
+}
+
+// Find returns the version with label in lang.
+func (m Manifest) Find(label, lang string) (Version, bool) {
+	for _, v := range m.Versions {
+		if v.Label == label && strings.EqualFold(v.Lang, lang) {
+			return v, true
+		}
+	}
+	return Version{}, false
+}
+
+// Put adds v, replacing the version with its label and language.
+func (m *Manifest) Put(v Version) {
+	for i, w := range m.Versions {
+		if w.Label == v.Label && strings.EqualFold(w.Lang, v.Lang) {
+			m.Versions[i] = v
+			return
+		}
+	}
+	m.Versions = append(m.Versions, v)
+}
+
+// ScanVersions lists the archives found on disk in the page's revisions
+// directory, in every language: content files named after their label and
+// directories holding an index file. The directory's _index file, which
+// hides it from Hugo, is not an archive. It is what a manifest is made
+// from when there is none.
+func (p Page) ScanVersions() []Version {
+	dir := p.RevisionsDir()
+	entries, _ := os.ReadDir(dir)
+	var out []Version
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() {
+			for _, v := range variants(filepath.Join(dir, name), "index", p.langs) {
+				out = append(out, Version{Label: name, Lang: v.Lang, Path: name + "/" + filepath.Base(v.Source)})
+			}
+		} else if IsContentFile(name) && !IsIndexFile(name) {
+			label, lang := splitLang(strings.TrimSuffix(name, filepath.Ext(name)), p.langs)
+			out = append(out, Version{Label: label, Lang: lang, Path: name})
+		}
+	}
+	return out
+}
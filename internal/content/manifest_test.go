@@ -0,0 +1,139 @@
+package content
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func writeFiles(t *testing.T, root string, paths ...string) {
+	t.Helper()
+	for _, path := range paths {
+		path = filepath.Join(root, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("---\ntitle: A\n---\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func labels(archives []Archive) []string {
+	var out []string
+	for _, a := range archives {
+		out = append(out, a.Label)
+	}
+	return out
+}
+
+// Without a manifest the revisions directory is scanned, and what is not
+// an archive of the page is left out.
+func TestArchivesScanned(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root,
+		"posts/p.md",
+		"posts/p.revisions/2024-01-01.md",
+		"posts/p.revisions/2024-03-01.md",
+		"posts/p.revisions/2024-03-01.zh.md",
+		"posts/p.revisions/2024-05-01.zh.md",
+		"posts/p.revisions/_index.md",
+		"posts/p.revisions/_index.zh.md",
+		"posts/p.revisions/notes.txt",
+	)
+	langs := []string{"en", "zh"}
+	tests := []struct {
+		source string
+		want   []string
+	}{
+		{"posts/p.md", []string{"2024-01-01", "2024-03-01"}},
+		{"posts/p.zh.md", []string{"2024-03-01", "2024-05-01"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			p := FromSource(filepath.Join(root, tt.source), langs)
+			if got := labels(p.Archives()); !slices.Equal(got, tt.want) {
+				t.Errorf("Archives = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	p := FromSource(filepath.Join(root, "posts/p.md"), langs)
+	if got := len(p.ScanVersions()); got != 4 {
+		t.Errorf("ScanVersions found %d versions, want 4", got)
+	}
+}
+
+// Bundle archives are directories; one holding only another language's
+// index belongs to that language.
+func TestArchivesBundles(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root,
+		"posts/b/index.md",
+		"posts/b.revisions/v1/index.md",
+		"posts/b.revisions/v1/image.png",
+		"posts/b.revisions/v2/index.zh.md",
+		"posts/b.revisions/_index.md",
+	)
+	p := FromSource(filepath.Join(root, "posts/b/index.md"), []string{"en", "zh"})
+	archives := p.Archives()
+	if got, want := labels(archives), []string{"v1"}; !slices.Equal(got, want) {
+		t.Fatalf("Archives = %v, want %v", got, want)
+	}
+	if want := filepath.Join(root, "posts/b.revisions/v1/index.md"); archives[0].File != want {
+		t.Errorf("File = %s, want %s", archives[0].File, want)
+	}
+}
+
+// Once there is a manifest, the versions it lists whose files exist are
+// the archives; stray files are not.
+func TestArchivesManifest(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root,
+		"posts/p.md",
+		"posts/p.revisions/2024-01-01.md",
+		"posts/p.revisions/2024-03-01.md",
+		"posts/p.revisions/2024-03-01.zh.md",
+		"posts/p.revisions/copy-of-2024-01-01.md",
+		"posts/p.revisions/_index.md",
+	)
+	p := FromSource(filepath.Join(root, "posts/p.md"), []string{"en", "zh"})
+	m := Manifest{Versions: []Version{
+		{Label: "2024-03-01", Path: "2024-03-01.md"},
+		{Label: "2024-03-01", Lang: "zh", Path: "2024-03-01.zh.md"},
+		{Label: "2024-01-01", Path: "2024-01-01.md"},
+		{Label: "2023-12-01", Path: "2023-12-01.md"}, // removed by hand
+	}}
+	if err := m.Write(p.ManifestPath()); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if got, want := labels(p.Archives()), []string{"2024-01-01", "2024-03-01"}; !slices.Equal(got, want) {
+		t.Errorf("Archives = %v, want %v", got, want)
+	}
+
+	read, ok, err := ReadManifest(p.ManifestPath())
+	if !ok || err != nil {
+		t.Fatalf("ReadManifest: %v, %v", ok, err)
+	}
+	var order []string
+	for _, v := range read.Versions {
+		order = append(order, v.Label+"."+v.Lang)
+	}
+	if want := []string{"2023-12-01.", "2024-01-01.", "2024-03-01.", "2024-03-01.zh"}; !slices.Equal(order, want) {
+		t.Errorf("versions = %v, want %v", order, want)
+	}
+	if _, ok := read.Find("2024-03-01", "ZH"); !ok {
+		t.Error("Find: languages should match without case")
+	}
+	read.Put(Version{Label: "2024-01-01", Path: "2024-01-01.md", Note: "first"})
+	if v, _ := read.Find("2024-01-01", ""); v.Note != "first" || len(read.Versions) != 4 {
+		t.Errorf("Put: got %+v in %d versions, want the note replaced in 4", v, len(read.Versions))
+	}
+}
+
+func TestReadManifestMissing(t *testing.T) {
+	m, ok, err := ReadManifest(filepath.Join(t.TempDir(), ManifestName))
+	if ok || err != nil || len(m.Versions) != 0 {
+		t.Errorf("ReadManifest = %v, %v, %v, want no manifest and no error", m, ok, err)
+	}
+}
@@ -1,10 +1,11 @@
 package fm
 
 import (
-	"bytes"
+	"encoding/json"
 	"errors"
-	"fmt"
 	"strings"
+
+	"gopkg.in/yaml.v3"
 )
 
 type Format int
@@ -13,233 +14,210 @@ const (
 	Unknown Format = iota
 	YAML
 	TOML
+	JSON
+	Org
 )
 
 type FrontMatter struct {
 	Format  Format
-	Header  string
-	Content string
+	Header  string // front matter text with "\n" line endings
+	Content string // everything after the front matter, byte for byte
+
+	// Layout of the input, kept so that Stringify reproduces an unchanged
+	// file exactly.
+	bom        bool   // input started with a UTF-8 byte order mark
+	leading    string // whitespace before the front matter
+	newline    string // line ending used by the front matter ("" means "\n")
+	rawHeader  string // header bytes as read, emitted while Header is unchanged
+	origHeader string // Header as parsed
+	openLine   string // opening delimiter line as written, without its line ending
+	closeLine  string // closing delimiter line as written, without its line ending
+	noFinalNL  bool   // the front matter ended the file without a line ending
 }
 
-// Parse minimal front matter, supports --- (YAML) and +++ (TOML)
+const utf8BOM = "\uFEFF"
+
+// Parse minimal front matter, supports --- (YAML), +++ (TOML), a leading
+// { ... } object (JSON) and #+KEY: lines (Org). Line endings, a byte order
+// mark and surrounding whitespace are recorded so that Stringify of an
+// unchanged result gives back the input.
 func Parse(input string) (FrontMatter, error) {
-	s := strings.TrimSpace(input)
-	if strings.HasPrefix(s, "---\n") {
-		end := strings.Index(s[4:], "\n---\n")
-		if end < 0 {
-			return FrontMatter{}, errors.New("yaml front matter not closed")
-		}
-		header := s[4 : 4+end]
-		body := s[4+end+5:]
-		return FrontMatter{Format: YAML, Header: header, Content: body}, nil
+	var f FrontMatter
+	s := input
+	if strings.HasPrefix(s, utf8BOM) {
+		f.bom = true
+		s = s[len(utf8BOM):]
 	}
-	if strings.HasPrefix(s, "+++\n") {
-		end := strings.Index(s[4:], "\n+++\n")
-		if end < 0 {
-			return FrontMatter{}, errors.New("toml front matter not closed")
-		}
-		header := s[4 : 4+end]
-		body := s[4+end+5:]
-		return FrontMatter{Format: TOML, Header: header, Content: body}, nil
-	}
-	// No front matter; treat whole as content
-	return FrontMatter{Format: Unknown, Header: "", Content: s}, nil
-}
+	body := strings.TrimLeft(s, " \t\r\n")
+	f.leading = s[:len(s)-len(body)]
 
-// Inject simple key-value into header; minimal and conservative.
-// If key is "draft", value is injected as boolean without quotes.
-func InjectKV(f FrontMatter, key, value string) (FrontMatter, error) {
-	if f.Format == Unknown {
-		// default to YAML
+	if nl, ok := openingDelimiter(body, "---"); ok {
 		f.Format = YAML
-		if key == "draft" {
-			f.Header = fmt.Sprintf("%s: %s\n", key, value)
-		} else {
-			f.Header = fmt.Sprintf("%s: %q\n", key, value)
+		return f, f.parseDelimited(body, "---", nl)
+	}
+	if nl, ok := openingDelimiter(body, "+++"); ok {
+		f.Format = TOML
+		return f, f.parseDelimited(body, "+++", nl)
+	}
+	if strings.HasPrefix(body, "#+") {
+		// Org keyword block: every leading line that starts with #+
+		lines := strings.SplitAfter(body, "\n")
+		n, size := 0, 0
+		for n < len(lines) && strings.HasPrefix(lines[n], "#+") {
+			size += len(lines[n])
+			n++
 		}
+		f.Format = Org
+		f.newline = detectNewline(body)
+		f.setRawHeader(body[:size])
+		f.noFinalNL = !strings.HasSuffix(body[:size], "\n")
+		f.Content = body[size:]
 		return f, nil
 	}
-	var buf bytes.Buffer
-	lines := strings.Split(strings.TrimRight(f.Header, "\n"), "\n")
-	replaced := false
-	for _, l := range lines {
-		if strings.HasPrefix(l, key+":") || strings.HasPrefix(l, key+" =") {
-			if f.Format == YAML {
-				if key == "draft" {
-					buf.WriteString(fmt.Sprintf("%s: %s\n", key, value))
-				} else {
-					buf.WriteString(fmt.Sprintf("%s: %q\n", key, value))
-				}
-			} else {
-				if key == "draft" {
-					buf.WriteString(fmt.Sprintf("%s = %s\n", key, value))
-				} else {
-					buf.WriteString(fmt.Sprintf("%s = %q\n", key, value))
-				}
-			}
-			replaced = true
-		} else {
-			buf.WriteString(l + "\n")
+	if strings.HasPrefix(body, "{") {
+		// JSON front matter is a leading object; anything that does not
+		// scan as one (a shortcode, say) is plain content.
+		if end := scanJSONObjectEnd(body); end > 0 && json.Valid([]byte(body[:end])) {
+			f.Format = JSON
+			f.newline = detectNewline(body[:end])
+			f.rawHeader = body[:end]
+			f.Header = strings.ReplaceAll(body[:end], "\r\n", "\n")
+			f.origHeader = f.Header
+			f.Content = body[end:]
+			return f, nil
 		}
 	}
-	if !replaced {
-		if f.Format == YAML {
-			if key == "draft" {
-				buf.WriteString(fmt.Sprintf("%s: %s\n", key, value))
-			} else {
-				buf.WriteString(fmt.Sprintf("%s: %q\n", key, value))
-			}
-		} else {
-			if key == "draft" {
-				buf.WriteString(fmt.Sprintf("%s = %s\n", key, value))
-			} else {
-				buf.WriteString(fmt.Sprintf("%s = %q\n", key, value))
-			}
-		}
-	}
-	f.Header = buf.String()
-	return f, nil
+	// No front matter; treat whole as content
+	return FrontMatter{Format: Unknown, Content: s, bom: f.bom, newline: detectNewline(s)}, nil
 }
 
-// InjectKVUnquoted injects key-value without quotes (for dates, lastmod, etc.)
-func InjectKVUnquoted(f FrontMatter, key, value string) (FrontMatter, error) {
-	if f.Format == Unknown {
-		f.Format = YAML
-		f.Header = fmt.Sprintf("%s: %s\n", key, value)
-		return f, nil
+// openingDelimiter reports whether s starts with delim on a line of its own
+// and returns that line's ending.
+func openingDelimiter(s, delim string) (string, bool) {
+	if !strings.HasPrefix(s, delim) {
+		return "", false
 	}
-	var buf bytes.Buffer
-	lines := strings.Split(strings.TrimRight(f.Header, "\n"), "\n")
-	replaced := false
-	for _, l := range lines {
-		if strings.HasPrefix(l, key+":") || strings.HasPrefix(l, key+" =") {
-			if f.Format == YAML {
-				buf.WriteString(fmt.Sprintf("%s: %s\n", key, value))
-			} else {
-				buf.WriteString(fmt.Sprintf("%s = %s\n", key, value))
-			}
-			replaced = true
-		} else {
-			buf.WriteString(l + "\n")
-		}
+	rest := strings.TrimLeft(s[len(delim):], " \t")
+	switch {
+	case strings.HasPrefix(rest, "\r\n"):
+		return "\r\n", true
+	case strings.HasPrefix(rest, "\n"):
+		return "\n", true
 	}
-	if !replaced {
-		if f.Format == YAML {
-			buf.WriteString(fmt.Sprintf("%s: %s\n", key, value))
-		} else {
-			buf.WriteString(fmt.Sprintf("%s = %s\n", key, value))
+	return "", false
+}
+
+// parseDelimited splits a ---/+++ block. The closing delimiter may be the
+// last line of the file, with or without a line ending.
+func (f *FrontMatter) parseDelimited(body, delim, nl string) error {
+	f.newline = nl
+	eol := strings.Index(body, "\n")
+	f.openLine = strings.TrimSuffix(body[:eol], "\r")
+	rest := body[eol+1:]
+	pos := 0
+	for pos <= len(rest) {
+		lineEnd := strings.IndexByte(rest[pos:], '\n')
+		next := len(rest)
+		line := rest[pos:]
+		if lineEnd >= 0 {
+			next = pos + lineEnd + 1
+			line = rest[pos : pos+lineEnd]
+		}
+		line = strings.TrimSuffix(line, "\r")
+		if strings.TrimRight(line, " \t") == delim {
+			f.setRawHeader(rest[:pos])
+			f.closeLine = line
+			f.noFinalNL = lineEnd < 0
+			f.Content = rest[next:]
+			return nil
+		}
+		if lineEnd < 0 {
+			break
 		}
+		pos = next
 	}
-	f.Header = buf.String()
-	return f, nil
+	if f.Format == TOML {
+		return errors.New("toml front matter not closed")
+	}
+	return errors.New("yaml front matter not closed")
 }
 
-// InjectList injects a list/array value for the given key.
-// YAML: key:\n  - v1\n  - v2
-// TOML: key = ["v1", "v2"]
-func InjectList(f FrontMatter, key string, values []string) (FrontMatter, error) {
-	if f.Format == Unknown {
-		f.Format = YAML
-	}
+func (f *FrontMatter) setRawHeader(raw string) {
+	f.rawHeader = raw
+	h := strings.ReplaceAll(raw, "\r\n", "\n")
+	f.Header = strings.TrimSuffix(h, "\n")
+	f.origHeader = f.Header
+}
 
-	var rendered string
-	if f.Format == YAML {
-		var b bytes.Buffer
-		b.WriteString(fmt.Sprintf("%s:\n", key))
-		for _, v := range values {
-			b.WriteString(fmt.Sprintf("  - %s\n", v))
-		}
-		rendered = b.String()
-	} else {
-		// TOML array
-		var quoted []string
-		for _, v := range values {
-			quoted = append(quoted, fmt.Sprintf("\"%s\"", v))
-		}
-		rendered = fmt.Sprintf("%s = [%s]\n", key, strings.Join(quoted, ", "))
+// detectNewline returns "\r\n" when the first line ending in s is CRLF.
+func detectNewline(s string) string {
+	if i := strings.IndexByte(s, '\n'); i > 0 && s[i-1] == '\r' {
+		return "\r\n"
 	}
+	return "\n"
+}
 
-	// Replace or append the key
-	var buf bytes.Buffer
-	lines := strings.Split(strings.TrimRight(f.Header, "\n"), "\n")
-	replaced := false
-	for i := 0; i < len(lines); i++ {
-		l := lines[i]
-		// If we find the key line, we need to skip its block (for YAML list) or single line (for TOML)
-		if strings.HasPrefix(strings.TrimSpace(l), key+":") || strings.HasPrefix(strings.TrimSpace(l), key+" =") || strings.HasPrefix(strings.TrimSpace(l), key+"=") {
-			// For YAML, skip the following indented list items
-			if f.Format == YAML {
-				// write rendered instead of existing block
-				buf.WriteString(rendered)
-				replaced = true
-				// skip subsequent indented lines (list items)
-				for i+1 < len(lines) {
-					next := lines[i+1]
-					if strings.HasPrefix(next, " ") || strings.HasPrefix(next, "\t") {
-						i++
-						continue
-					}
-					break
-				}
-			} else {
-				// TOML: replace this line
-				buf.WriteString(rendered)
-				replaced = true
-			}
-		} else {
-			buf.WriteString(l + "\n")
-		}
-	}
-	if !replaced {
-		buf.WriteString(rendered)
+// rawValue is written as-is, without quoting (dates, booleans, numbers).
+type rawValue string
+
+// Inject simple key-value into header; minimal and conservative.
+// If key is "draft", value is injected as boolean without quotes.
+func InjectKV(f FrontMatter, key, value string) (FrontMatter, error) {
+	if key == "draft" {
+		return setValue(f, key, rawValue(value))
 	}
+	return setValue(f, key, value)
+}
 
-	f.Header = buf.String()
-	return f, nil
+// InjectKVUnquoted injects key-value without quotes (for dates, lastmod, etc.)
+func InjectKVUnquoted(f FrontMatter, key, value string) (FrontMatter, error) {
+	return setValue(f, key, rawValue(value))
+}
+
+// InjectList injects a list/array value for the given key.
+// YAML: key:\n  - v1\n  - v2
+// TOML: key = ["v1", "v2"]
+func InjectList(f FrontMatter, key string, values []string) (FrontMatter, error) {
+	return setValue(f, key, values)
 }
 
 // GetList tries to read a YAML/TOML list into slice of strings.
 // Falls back to comma-separated string if present.
 func GetList(f FrontMatter, key string) []string {
 	var out []string
-	lines := strings.Split(strings.TrimRight(f.Header, "\n"), "\n")
-	for i := 0; i < len(lines); i++ {
-		l := strings.TrimSpace(lines[i])
-		if f.Format == YAML {
-			if strings.HasPrefix(l, key+":") {
-				// collect subsequent indented list items
-				for i+1 < len(lines) {
-					next := lines[i+1]
-					if strings.HasPrefix(next, " ") || strings.HasPrefix(next, "\t") {
-						item := strings.TrimSpace(next)
-						// expect format "- value"
-						item = strings.TrimPrefix(item, "-")
-						item = strings.TrimSpace(item)
-						out = append(out, item)
-						i++
-						continue
+	switch f.Format {
+	case YAML:
+		if d, err := parseYAML(f.Header); err == nil {
+			if n, ok := d.lookup([]string{key}); ok && n.Kind == yaml.SequenceNode {
+				for _, item := range n.Content {
+					out = append(out, yamlScalarString(resolveAlias(item)))
+				}
+			}
+		}
+	case TOML:
+		if d, err := parseTOML(f.Header); err == nil {
+			if m, err := d.decode(); err == nil {
+				if items, ok := m[key].([]any); ok {
+					for _, item := range items {
+						out = append(out, tomlScalarString(item))
 					}
-					break
 				}
-				break
 			}
-		} else if f.Format == TOML {
-			if strings.HasPrefix(l, key+" =") || strings.HasPrefix(l, key+"=") {
-				parts := strings.SplitN(l, "=", 2)
-				if len(parts) == 2 {
-					arr := strings.TrimSpace(parts[1])
-					arr = strings.TrimPrefix(arr, "[")
-					arr = strings.TrimSuffix(arr, "]")
-					if strings.TrimSpace(arr) != "" {
-						items := strings.Split(arr, ",")
-						for _, it := range items {
-							it = strings.TrimSpace(it)
-							it = strings.Trim(it, "\"")
-							out = append(out, it)
-						}
+		}
+	case JSON:
+		if d, err := parseJSON(f.Header); err == nil {
+			if m, err := d.decode(); err == nil {
+				if items, ok := m[key].([]any); ok {
+					for _, item := range items {
+						out = append(out, jsonScalarString(item))
 					}
 				}
-				break
+			}
+		}
+	case Org:
+		if v, ok := parseOrg(f.Header).lookup(key); ok {
+			if items, ok := v.([]string); ok {
+				out = items
 			}
 		}
 	}
@@ -256,83 +234,232 @@ func GetList(f FrontMatter, key string) []string {
 	return out
 }
 
+// Stringify renders f back into a file. Parts that were not edited are
+// written exactly as they were read.
 func Stringify(f FrontMatter) string {
+	nl := f.newline
+	if nl == "" {
+		nl = "\n"
+	}
+	var b strings.Builder
+	if f.bom {
+		b.WriteString(utf8BOM)
+	}
+	if f.Format == Unknown {
+		b.WriteString(f.Content)
+		return b.String()
+	}
+	b.WriteString(f.leading)
+
+	// header renders the front matter lines, each with its line ending.
+	header := func() string {
+		if f.Header == f.origHeader && f.rawHeader != "" {
+			return f.rawHeader
+		}
+		h := strings.TrimRight(f.Header, "\n")
+		if h == "" {
+			return ""
+		}
+		return strings.ReplaceAll(h, "\n", nl) + nl
+	}
+	finalNL := nl
+	if f.noFinalNL && f.Content == "" {
+		finalNL = ""
+	}
+
 	switch f.Format {
-	case YAML:
-		return fmt.Sprintf("---\n%s\n---\n%s", strings.TrimRight(f.Header, "\n"), f.Content)
-	case TOML:
-		return fmt.Sprintf("+++\n%s\n+++\n%s", strings.TrimRight(f.Header, "\n"), f.Content)
-	default:
-		return f.Content
+	case YAML, TOML:
+		delim := "---"
+		if f.Format == TOML {
+			delim = "+++"
+		}
+		openLine, closeLine := f.openLine, f.closeLine
+		if openLine == "" {
+			openLine = delim
+		}
+		if closeLine == "" {
+			closeLine = delim
+		}
+		b.WriteString(openLine + nl + header() + closeLine + finalNL)
+	case JSON:
+		if f.Header == f.origHeader && f.rawHeader != "" {
+			b.WriteString(f.rawHeader)
+		} else {
+			b.WriteString(strings.ReplaceAll(f.Header, "\n", nl))
+		}
+	case Org:
+		h := header()
+		if f.noFinalNL && f.Content == "" {
+			h = strings.TrimSuffix(h, nl)
+		}
+		b.WriteString(h)
 	}
+	b.WriteString(f.Content)
+	return b.String()
 }
 
 // GetValue extracts a field value from front matter (simple string extraction)
 func GetValue(f FrontMatter, key string) string {
-	lines := strings.Split(f.Header, "\n")
-	for _, l := range lines {
-		l = strings.TrimSpace(l)
-		if f.Format == YAML {
-			if strings.HasPrefix(l, key+":") {
-				val := strings.TrimSpace(strings.TrimPrefix(l, key+":"))
-				// Remove quotes if present
-				val = strings.Trim(val, `"`)
-				val = strings.Trim(val, "'")
-				return val
-			}
-		} else if f.Format == TOML {
-			if strings.HasPrefix(l, key+" =") || strings.HasPrefix(l, key+"=") {
-				parts := strings.SplitN(l, "=", 2)
-				if len(parts) == 2 {
-					val := strings.TrimSpace(parts[1])
-					val = strings.Trim(val, `"`)
-					val = strings.Trim(val, "'")
-					return val
-				}
-			}
+	switch f.Format {
+	case YAML:
+		d, err := parseYAML(f.Header)
+		if err != nil {
+			return ""
 		}
+		n, _ := d.lookup([]string{key})
+		return yamlScalarString(n)
+	case TOML:
+		d, err := parseTOML(f.Header)
+		if err != nil {
+			return ""
+		}
+		m, err := d.decode()
+		if err != nil {
+			return ""
+		}
+		return tomlScalarString(m[key])
+	case JSON:
+		d, err := parseJSON(f.Header)
+		if err != nil {
+			return ""
+		}
+		m, err := d.decode()
+		if err != nil {
+			return ""
+		}
+		return jsonScalarString(m[key])
+	case Org:
+		v, _ := parseOrg(f.Header).lookup(key)
+		s, _ := v.(string)
+		return s
 	}
 	return ""
 }
 
-// InjectBuildOptions injects build field as proper YAML/TOML structure (Hugo 0.145+)
+// InjectBuildOptions injects build field as proper YAML/TOML/JSON structure (Hugo 0.145+)
 func InjectBuildOptions(f FrontMatter) (FrontMatter, error) {
 	if f.Format == Unknown {
 		f.Format = YAML
 	}
-
-	var buildBlock string
-	if f.Format == YAML {
-		buildBlock = "build:\n  list: never\n  render: true\n"
-	} else {
-		buildBlock = "[build]\nlist = \"never\"\nrender = true\n"
+	var exists bool
+	switch f.Format {
+	case YAML:
+		d, err := parseYAML(f.Header)
+		if err != nil {
+			return f, err
+		}
+		_, exists = d.lookup([]string{"build"})
+	case TOML:
+		d, err := parseTOML(f.Header)
+		if err != nil {
+			return f, err
+		}
+		exists = d.has([]string{"build"})
+	case JSON:
+		d, err := parseJSON(f.Header)
+		if err != nil {
+			return f, err
+		}
+		_, _, exists = d.find([]string{"build"})
+	case Org:
+		_, exists = parseOrg(f.Header).decode()["build"]
 	}
-
-	// Check if build already exists
-	if strings.Contains(f.Header, "build:") || strings.Contains(f.Header, "[build]") {
+	if exists {
 		// Already exists, don't duplicate
 		return f, nil
 	}
-
-	f.Header = strings.TrimRight(f.Header, "\n") + "\n" + buildBlock
-	return f, nil
+	return setValue(f, "build", map[string]any{"list": "never", "render": true})
 }
 
 // RemoveKey removes a field from front matter
 func RemoveKey(f FrontMatter, key string) (FrontMatter, error) {
-	var buf bytes.Buffer
-	lines := strings.Split(strings.TrimRight(f.Header, "\n"), "\n")
-
-	for _, l := range lines {
-		// Skip lines that start with this key
-		if strings.HasPrefix(strings.TrimSpace(l), key+":") ||
-			strings.HasPrefix(strings.TrimSpace(l), key+" =") ||
-			strings.HasPrefix(strings.TrimSpace(l), key+"=") {
-			continue
-		}
-		buf.WriteString(l + "\n")
+	switch f.Format {
+	case YAML:
+		d, err := parseYAML(f.Header)
+		if err != nil {
+			return f, err
+		}
+		if err := d.remove([]string{key}); err != nil {
+			return f, err
+		}
+		f.Header = d.String()
+	case TOML:
+		d, err := parseTOML(f.Header)
+		if err != nil {
+			return f, err
+		}
+		if err := d.remove([]string{key}); err != nil {
+			return f, err
+		}
+		f.Header = d.String()
+	case JSON:
+		d, err := parseJSON(f.Header)
+		if err != nil {
+			return f, err
+		}
+		if err := d.remove([]string{key}); err != nil {
+			return f, err
+		}
+		f.Header = d.String()
+	case Org:
+		d := parseOrg(f.Header)
+		if err := d.remove(key); err != nil {
+			return f, err
+		}
+		f.Header = d.String()
 	}
+	return f, nil
+}
+
+// setValue stores value under key through the editor for f's format.
+func setValue(f FrontMatter, key string, value any) (FrontMatter, error) {
+	return setPath(f, []string{key}, value)
+}
 
-	f.Header = buf.String()
+// setPath stores value at path through the editor for f's format.
+// Front matter without a header gets a YAML one, and so does an Org header
+// that has to hold a nested value.
+func setPath(f FrontMatter, path []string, value any) (FrontMatter, error) {
+	if f.Format == Unknown {
+		f.Format = YAML
+	}
+	if f.Format == Org && (len(path) > 1 || orgNested(value)) {
+		f = orgAsYAML(f)
+	}
+	switch f.Format {
+	case YAML:
+		d, err := parseYAML(f.Header)
+		if err != nil {
+			return f, err
+		}
+		if err := d.set(path, yamlValue(value)); err != nil {
+			return f, err
+		}
+		f.Header = d.String()
+	case TOML:
+		d, err := parseTOML(f.Header)
+		if err != nil {
+			return f, err
+		}
+		if err := d.set(path, value); err != nil {
+			return f, err
+		}
+		f.Header = d.String()
+	case JSON:
+		d, err := parseJSON(f.Header)
+		if err != nil {
+			return f, err
+		}
+		if err := d.set(path, value); err != nil {
+			return f, err
+		}
+		f.Header = d.String()
+	case Org:
+		d := parseOrg(f.Header)
+		if err := d.set(strings.Join(path, "."), value); err != nil {
+			return f, err
+		}
+		f.Header = d.String()
+	}
 	return f, nil
 }
@@ -0,0 +1,249 @@
+package fm
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		format  Format
+		header  string
+		content string
+	}{
+		{"yaml", "---\ntitle: A\n---\nbody\n", YAML, "title: A", "body\n"},
+		{"toml", "+++\ntitle = 'A'\n+++\nbody\n", TOML, "title = 'A'", "body\n"},
+		{"json", "{\n  \"title\": \"A\"\n}\nbody\n", JSON, "{\n  \"title\": \"A\"\n}", "\nbody\n"},
+		{"org", "#+TITLE: A\n#+DATE: 2024-06-15\nbody\n", Org, "#+TITLE: A\n#+DATE: 2024-06-15", "body\n"},
+		{"none", "just text\n", Unknown, "", "just text\n"},
+		{"shortcode is not json", "{{< note >}}\n", Unknown, "", "{{< note >}}\n"},
+		{"closing delimiter ends the file", "---\ntitle: A\n---", YAML, "title: A", ""},
+		{"crlf", "---\r\ntitle: A\r\n---\r\nbody\r\n", YAML, "title: A", "body\r\n"},
+		{"bom", "\uFEFF---\ntitle: A\n---\nbody\n", YAML, "title: A", "body\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if f.Format != tt.format {
+				t.Errorf("Format = %v, want %v", f.Format, tt.format)
+			}
+			if f.Header != tt.header {
+				t.Errorf("Header = %q, want %q", f.Header, tt.header)
+			}
+			if f.Content != tt.content {
+				t.Errorf("Content = %q, want %q", f.Content, tt.content)
+			}
+		})
+	}
+}
+
+func TestParseUnclosed(t *testing.T) {
+	for _, input := range []string{"---\ntitle: A\n", "+++\ntitle = 'A'\n"} {
+		if _, err := Parse(input); err == nil {
+			t.Errorf("Parse(%q): want an error", input)
+		}
+	}
+}
+
+// An unchanged front matter is written back byte for byte.
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"yaml", "---\ntitle: A\n# comment\ntags: [a, b]\n---\nbody\n"},
+		{"toml", "+++\ntitle = \"A\"\n\n[params]\nx = 1\n+++\nbody\n"},
+		{"json", "{\n  \"title\": \"A\"\n}\nbody\n"},
+		{"org", "#+TITLE: A\n#+tags[]: a b\n\n* Heading\n"},
+		{"crlf", "---\r\ntitle: A\r\n---\r\nbody\r\n"},
+		{"crlf toml", "+++\r\ntitle = \"A\"\r\n+++\r\nbody\r\n"},
+		{"crlf org", "#+TITLE: A\r\nbody\r\n"},
+		{"bom", "\uFEFF---\ntitle: A\n---\nbody\n"},
+		{"bom without front matter", "\uFEFFbody\n"},
+		{"no final newline", "---\ntitle: A\n---"},
+		{"no final newline in body", "---\ntitle: A\n---\nbody"},
+		{"org without final newline", "#+TITLE: A"},
+		{"leading blank lines", "\n\n---\ntitle: A\n---\nbody\n"},
+		{"delimiter with trailing spaces", "---  \ntitle: A\n---\t\nbody\n"},
+		{"blank line after front matter", "---\ntitle: A\n---\n\n\nbody\n"},
+		{"empty yaml", "---\n---\nbody\n"},
+		{"no front matter", "body\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if got := Stringify(f); got != tt.input {
+				t.Errorf("Stringify = %q, want %q", got, tt.input)
+			}
+		})
+	}
+}
+
+// Edits keep the line endings, byte order mark and body of the input.
+func TestEditKeepsLayout(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			"crlf",
+			"---\r\ntitle: A\r\n---\r\nbody\r\n",
+			"---\r\ntitle: A\r\nlastmod: 2025-01-01\r\n---\r\nbody\r\n",
+		},
+		{
+			"bom",
+			"\uFEFF+++\ntitle = 'A'\n+++\nbody\n",
+			"\uFEFF+++\ntitle = 'A'\nlastmod = 2025-01-01\n+++\nbody\n",
+		},
+		{
+			"no final newline",
+			"---\ntitle: A\n---",
+			"---\ntitle: A\nlastmod: 2025-01-01\n---",
+		},
+		{
+			"crlf org",
+			"#+TITLE: A\r\nbody\r\n",
+			"#+TITLE: A\r\n#+LASTMOD: 2025-01-01\r\nbody\r\n",
+		},
+		{
+			"no front matter gets yaml",
+			"body\n",
+			"---\nlastmod: 2025-01-01\n---\nbody\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			f, err = InjectKVUnquoted(f, "lastmod", "2025-01-01")
+			if err != nil {
+				t.Fatalf("InjectKVUnquoted: %v", err)
+			}
+			if got := Stringify(f); got != tt.want {
+				t.Errorf("Stringify =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInjectBuildOptions(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			"yaml",
+			"---\ntitle: A\n---\n",
+			"---\ntitle: A\nbuild:\n  list: never\n  render: true\n---\n",
+		},
+		{
+			"toml",
+			"+++\ntitle = 'A'\n+++\n",
+			"+++\ntitle = 'A'\n\n[build]\nlist = \"never\"\nrender = true\n+++\n",
+		},
+		{
+			"json",
+			"{\n  \"title\": \"A\"\n}\n",
+			"{\n  \"title\": \"A\",\n  \"build\": {\n    \"list\": \"never\",\n    \"render\": true\n  }\n}\n",
+		},
+		{
+			"existing build is kept",
+			"---\nbuild:\n  list: always\n---\n",
+			"---\nbuild:\n  list: always\n---\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			f, err = InjectBuildOptions(f)
+			if err != nil {
+				t.Fatalf("InjectBuildOptions: %v", err)
+			}
+			if got := Stringify(f); got != tt.want {
+				t.Errorf("Stringify =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetList(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"yaml block", "---\nh:\n  - a\n  - b\n---\n", []string{"a", "b"}},
+		{"yaml flow", "---\nh: [a, b]\n---\n", []string{"a", "b"}},
+		{"yaml comma string", "---\nh: a, b\n---\n", []string{"a", "b"}},
+		{"toml", "+++\nh = ['a', 'b']\n+++\n", []string{"a", "b"}},
+		{"json", "{\"h\": [\"a\", \"b\"]}\n", []string{"a", "b"}},
+		{"org", "#+h[]: a b\n", []string{"a", "b"}},
+		{"org repeated", "#+h: a c\n#+h: b\n", []string{"a c", "b"}},
+		{"missing", "---\ntitle: A\n---\n", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if got := GetList(f, "h"); !slices.Equal(got, tt.want) {
+				t.Errorf("GetList = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// editTest is an edit of one front matter and the file it should give.
+type editTest struct {
+	name  string
+	input string
+	edit  func(FrontMatter) (FrontMatter, error)
+	want  string
+}
+
+func runEditTests(t *testing.T, tests []editTest) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			f, err = tt.edit(f)
+			if err != nil {
+				t.Fatalf("edit: %v", err)
+			}
+			if got := Stringify(f); got != tt.want {
+				t.Errorf("Stringify =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func set(path string, v any) func(FrontMatter) (FrontMatter, error) {
+	return func(f FrontMatter) (FrontMatter, error) { return Set(f, path, v) }
+}
+
+func setList(key string, values ...string) func(FrontMatter) (FrontMatter, error) {
+	return func(f FrontMatter) (FrontMatter, error) { return InjectList(f, key, values) }
+}
+
+func remove(path string) func(FrontMatter) (FrontMatter, error) {
+	return func(f FrontMatter) (FrontMatter, error) { return Delete(f, path) }
+}
@@ -0,0 +1,316 @@
+package fm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// jsonDoc is a JSON front matter object. Members are located by scanning the
+// text, and edits replace only the bytes of the touched value, so the
+// object's own formatting and member order are kept.
+type jsonDoc struct {
+	text string
+}
+
+type jsonMember struct {
+	key                    string
+	start                  int // offset of the key's opening quote
+	valueStart, valueEnd   int
+	nextStart, prevCommaAt int // -1 when there is no next/previous member
+}
+
+// scanJSONObjectEnd returns the offset just past the object that starts at
+// s[0], or -1 when the object is not closed.
+func scanJSONObjectEnd(s string) int {
+	depth := 0
+	inString := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if inString {
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return -1
+}
+
+func parseJSON(header string) (*jsonDoc, error) {
+	if !json.Valid([]byte(header)) {
+		return nil, fmt.Errorf("parse json front matter: invalid JSON object")
+	}
+	return &jsonDoc{text: header}, nil
+}
+
+func (d *jsonDoc) String() string {
+	return d.text
+}
+
+func (d *jsonDoc) decode() (map[string]any, error) {
+	dec := json.NewDecoder(strings.NewReader(d.text))
+	dec.UseNumber()
+	m := map[string]any{}
+	if err := dec.Decode(&m); err != nil {
+		return nil, fmt.Errorf("parse json front matter: %w", err)
+	}
+	return m, nil
+}
+
+// members lists the members of the object that opens at offset start.
+func (d *jsonDoc) members(start int) []jsonMember {
+	s := d.text
+	var out []jsonMember
+	i := start + 1
+	prevComma := -1
+	for {
+		i = skipJSONSpace(s, i)
+		if i >= len(s) || s[i] == '}' {
+			break
+		}
+		keyEnd := scanJSONValue(s, i)
+		var key string
+		_ = json.Unmarshal([]byte(s[i:keyEnd]), &key)
+		j := skipJSONSpace(s, keyEnd)
+		j = skipJSONSpace(s, j+1) // past ':'
+		valueEnd := scanJSONValue(s, j)
+		m := jsonMember{key: key, start: i, valueStart: j, valueEnd: valueEnd, nextStart: -1, prevCommaAt: prevComma}
+		i = skipJSONSpace(s, valueEnd)
+		if i < len(s) && s[i] == ',' {
+			prevComma = i
+			m.nextStart = skipJSONSpace(s, i+1)
+			i++
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// find returns the member at path and the offset of its parent object.
+func (d *jsonDoc) find(path []string) (jsonMember, int, bool) {
+	obj := skipJSONSpace(d.text, 0)
+	for depth, key := range path {
+		var found *jsonMember
+		for _, m := range d.members(obj) {
+			if m.key == key {
+				m := m
+				found = &m
+			}
+		}
+		if found == nil {
+			return jsonMember{}, obj, false
+		}
+		if depth == len(path)-1 {
+			return *found, obj, true
+		}
+		if d.text[found.valueStart] != '{' {
+			return jsonMember{}, obj, false
+		}
+		obj = found.valueStart
+	}
+	return jsonMember{}, obj, false
+}
+
+// set writes value at path, inserting new members at the end of the
+// innermost existing object.
+func (d *jsonDoc) set(path []string, value any) error {
+	if m, obj, ok := d.find(path); ok {
+		rendered := d.render(value, lineIndent(d.text, m.start), d.multiline(obj))
+		d.text = d.text[:m.valueStart] + rendered + d.text[m.valueEnd:]
+		return nil
+	}
+	// Walk down to the deepest existing object along path.
+	obj := skipJSONSpace(d.text, 0)
+	n := 0
+	for ; n < len(path)-1; n++ {
+		m, _, ok := d.find(path[:n+1])
+		if !ok {
+			break
+		}
+		if d.text[m.valueStart] != '{' {
+			return fmt.Errorf("json front matter: %s is not an object", strings.Join(path[:n+1], "."))
+		}
+		obj = m.valueStart
+	}
+	for i := len(path) - 1; i > n; i-- {
+		value = map[string]any{path[i]: value}
+	}
+	d.insertMember(obj, path[n], value)
+	return nil
+}
+
+func (d *jsonDoc) insertMember(obj int, key string, value any) {
+	members := d.members(obj)
+	closeAt := scanJSONValue(d.text, obj) - 1
+	keyJSON, _ := marshalJSON(key)
+	if len(members) == 0 {
+		indent := lineIndent(d.text, obj)
+		if !strings.Contains(d.text[obj:closeAt], "\n") && obj != skipJSONSpace(d.text, 0) {
+			d.text = d.text[:obj+1] + keyJSON + ": " + d.render(value, "", false) + d.text[closeAt:]
+			return
+		}
+		unit := d.indentUnit()
+		member := "\n" + indent + unit + keyJSON + ": " + d.render(value, indent+unit, true) + "\n" + indent
+		d.text = d.text[:obj+1] + member + d.text[closeAt:]
+		return
+	}
+	last := members[len(members)-1]
+	// Reuse the whitespace that precedes the first member.
+	sep := " "
+	if gap := d.text[obj+1 : members[0].start]; strings.Contains(gap, "\n") {
+		sep = gap[strings.LastIndex(gap, "\n"):]
+	}
+	member := "," + sep + keyJSON + ": " + d.render(value, lineIndent(d.text, last.start), d.multiline(obj))
+	d.text = d.text[:last.valueEnd] + member + d.text[last.valueEnd:]
+}
+
+// remove deletes the member at path together with its separating comma.
+func (d *jsonDoc) remove(path []string) error {
+	m, _, ok := d.find(path)
+	if !ok {
+		return nil
+	}
+	switch {
+	case m.nextStart >= 0:
+		d.text = d.text[:m.start] + d.text[m.nextStart:]
+	case m.prevCommaAt >= 0:
+		d.text = d.text[:m.prevCommaAt] + d.text[m.valueEnd:]
+	default:
+		// Only member: keep the braces and the whitespace after the value.
+		open := strings.LastIndex(d.text[:m.start], "{")
+		d.text = d.text[:open+1] + d.text[skipJSONSpace(d.text, m.valueEnd):]
+	}
+	return nil
+}
+
+// indentUnit guesses one level of indentation from the first member.
+func (d *jsonDoc) indentUnit() string {
+	obj := skipJSONSpace(d.text, 0)
+	if ms := d.members(obj); len(ms) > 0 {
+		if ind := lineIndent(d.text, ms[0].start); ind != "" && strings.Contains(d.text[obj:ms[0].start], "\n") {
+			return ind
+		}
+	}
+	return "  "
+}
+
+// multiline reports whether the object at offset obj spans several lines.
+func (d *jsonDoc) multiline(obj int) bool {
+	return strings.Contains(d.text[obj:scanJSONValue(d.text, obj)], "\n")
+}
+
+// render marshals v; composite values are indented when multiline is set.
+func (d *jsonDoc) render(v any, indent string, multiline bool) string {
+	switch x := v.(type) {
+	case rawValue:
+		if json.Valid([]byte(x)) {
+			return string(x)
+		}
+		v = string(x)
+	case time.Time:
+		v = x.Format(time.RFC3339)
+	}
+	if multiline {
+		switch v.(type) {
+		case map[string]any, []any:
+			var buf bytes.Buffer
+			enc := json.NewEncoder(&buf)
+			enc.SetEscapeHTML(false)
+			enc.SetIndent(indent, d.indentUnit())
+			_ = enc.Encode(v)
+			return strings.TrimRight(buf.String(), "\n")
+		}
+	}
+	if list, ok := v.([]string); ok {
+		// ["a", "b"] reads better than ["a","b"] in front matter.
+		items := make([]string, len(list))
+		for i, s := range list {
+			items[i], _ = marshalJSON(s)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	}
+	out, _ := marshalJSON(v)
+	return out
+}
+
+func marshalJSON(v any) (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return "", err
+	}
+	return strings.TrimRight(buf.String(), "\n"), nil
+}
+
+func skipJSONSpace(s string, i int) int {
+	for i < len(s) && (s[i] == ' ' || s[i] == '\t' || s[i] == '\n' || s[i] == '\r') {
+		i++
+	}
+	return i
+}
+
+// scanJSONValue returns the offset just past the value starting at s[i].
+func scanJSONValue(s string, i int) int {
+	if i >= len(s) {
+		return i
+	}
+	switch s[i] {
+	case '{', '[':
+		if end := scanJSONObjectEnd(s[i:]); end >= 0 {
+			return i + end
+		}
+		return len(s)
+	case '"':
+		for j := i + 1; j < len(s); j++ {
+			if s[j] == '\\' {
+				j++
+			} else if s[j] == '"' {
+				return j + 1
+			}
+		}
+		return len(s)
+	}
+	j := i
+	for j < len(s) && !strings.ContainsRune(",}] \t\r\n", rune(s[j])) {
+		j++
+	}
+	return j
+}
+
+// lineIndent returns the leading whitespace of the line containing offset i.
+func lineIndent(s string, i int) string {
+	start := strings.LastIndex(s[:i], "\n") + 1
+	j := start
+	for j < len(s) && (s[j] == ' ' || s[j] == '\t') {
+		j++
+	}
+	return s[start:j]
+}
+
+// jsonScalarString converts a decoded JSON value to a plain string.
+func jsonScalarString(v any) string {
+	switch x := v.(type) {
+	case nil, map[string]any, []any:
+		return ""
+	case string:
+		return x
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,69 @@
+package fm
+
+import "testing"
+
+func TestJSONEdit(t *testing.T) {
+	runEditTests(t, []editTest{
+		{
+			"member order and layout are kept",
+			"{\n  \"title\": \"A\",\n  \"draft\": false\n}\nbody\n",
+			set("lastmod", rawValue("2025-01-01T00:00:00Z")),
+			"{\n  \"title\": \"A\",\n  \"draft\": false,\n  \"lastmod\": \"2025-01-01T00:00:00Z\"\n}\nbody\n",
+		},
+		{
+			"replaced value",
+			"{\n  \"title\": \"A\",\n  \"draft\": false\n}\n",
+			set("title", "B"),
+			"{\n  \"title\": \"B\",\n  \"draft\": false\n}\n",
+		},
+		{
+			"single-line object stays on one line",
+			"{\"title\": \"A\"}\nbody\n",
+			setList("h", "a", "b"),
+			"{\"title\": \"A\", \"h\": [\"a\", \"b\"]}\nbody\n",
+		},
+		{
+			"nested member",
+			"{\n  \"params\": {\n    \"x\": 1\n  }\n}\n",
+			set("params.y", "z"),
+			"{\n  \"params\": {\n    \"x\": 1,\n    \"y\": \"z\"\n  }\n}\n",
+		},
+		{
+			"remove a middle member",
+			"{\n  \"a\": 1,\n  \"b\": 2,\n  \"c\": 3\n}\n",
+			remove("b"),
+			"{\n  \"a\": 1,\n  \"c\": 3\n}\n",
+		},
+		{
+			"remove the last member",
+			"{\n  \"a\": 1,\n  \"b\": 2\n}\n",
+			remove("b"),
+			"{\n  \"a\": 1\n}\n",
+		},
+		{
+			"crlf",
+			"{\r\n  \"title\": \"A\"\r\n}\r\nbody\r\n",
+			set("title", "B"),
+			"{\r\n  \"title\": \"B\"\r\n}\r\nbody\r\n",
+		},
+	})
+}
+
+func TestJSONGet(t *testing.T) {
+	f, err := Parse("{\"n\": 3, \"x\": 1.5, \"params\": {\"author\": \"X\"}}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := Get(f, "n"); !ok || v.Kind != IntKind {
+		t.Errorf("n = %v, want an int", v.Kind)
+	}
+	if v, ok := Get(f, "x"); !ok || v.Kind != FloatKind {
+		t.Errorf("x = %v, want a float", v.Kind)
+	}
+	if got := GetValue(f, "params"); got != "" {
+		t.Errorf("GetValue of an object = %q, want none", got)
+	}
+	if v, ok := Get(f, "params.author"); !ok || v.String() != "X" {
+		t.Errorf("params.author = %q, %v", v.String(), ok)
+	}
+}
@@ -0,0 +1,302 @@
+package fm
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// orgDoc is an Org-mode keyword block (#+KEY: value lines) read the way
+// Hugo reads it: keys are case-insensitive, "#+key[]: a b" is a list split
+// on whitespace and a keyword repeated on several lines is a list too.
+//
+// Org keywords are flat, and Hugo does not split them on dots: #+build.list
+// is a param of its own, not list inside build. A header that has to hold a
+// nested map is therefore rewritten as YAML front matter by orgAsYAML, which
+// Hugo reads in Org files as well.
+type orgDoc struct {
+	lines []string
+}
+
+type orgKeyword struct {
+	line  int
+	key   string // as written, without "[]"
+	list  bool   // written as key[]
+	value string
+}
+
+var orgKeywordRe = regexp.MustCompile(`^\s*#\+([^:\s]+):\s?(.*)$`)
+
+// orgDateRe matches Org timestamps such as <2024-06-15 Sat> or
+// [2024-06-15 Sat 10:30], of which Hugo keeps only the date.
+var orgDateRe = regexp.MustCompile(`[<\[](\d{4}-\d{2}-\d{2}) .*[>\]]`)
+
+func parseOrg(header string) *orgDoc {
+	return &orgDoc{lines: splitLines(header)}
+}
+
+func (d *orgDoc) String() string {
+	if len(d.lines) == 0 {
+		return ""
+	}
+	return strings.Join(d.lines, "\n") + "\n"
+}
+
+func (d *orgDoc) keywords() []orgKeyword {
+	var out []orgKeyword
+	for i, l := range d.lines {
+		m := orgKeywordRe.FindStringSubmatch(l)
+		if m == nil {
+			continue
+		}
+		k := orgKeyword{line: i, key: m[1], value: strings.TrimSpace(m[2])}
+		if strings.HasSuffix(k.key, "[]") {
+			k.key = strings.TrimSuffix(k.key, "[]")
+			k.list = true
+		}
+		out = append(out, k)
+	}
+	return out
+}
+
+// lookup returns a string, or a []string for list keywords.
+func (d *orgDoc) lookup(key string) (any, bool) {
+	var values []string
+	list := false
+	for _, k := range d.keywords() {
+		if !strings.EqualFold(k.key, key) {
+			continue
+		}
+		if k.list {
+			list = true
+			values = append(values, strings.Fields(k.value)...)
+			continue
+		}
+		values = append(values, k.value)
+	}
+	switch {
+	case values == nil:
+		return nil, false
+	case list || len(values) > 1:
+		return values, true
+	}
+	v := values[0]
+	if slices.Contains(orgDateKeys, strings.ToLower(key)) {
+		if m := orgDateRe.FindStringSubmatch(v); m != nil {
+			v = m[1]
+		}
+	}
+	return v, true
+}
+
+// decode returns all keywords as Hugo would see them, keyed in lower case.
+func (d *orgDoc) decode() map[string]any {
+	out := map[string]any{}
+	seen := map[string]bool{}
+	for _, k := range d.keywords() {
+		key := strings.ToLower(k.key)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		out[key], _ = d.lookup(key)
+	}
+	return out
+}
+
+// set writes key, replacing every existing spelling of it. The first
+// existing line keeps its position and case; new keys go after the last
+// keyword, in the case the block already uses. Nested values cannot be
+// written as keywords.
+func (d *orgDoc) set(key string, value any) error {
+	if orgNested(value) {
+		return fmt.Errorf("org keyword %s cannot hold a map", key)
+	}
+
+	at := -1
+	name := key
+	if d.upperCase() {
+		name = strings.ToUpper(key)
+	}
+	var drop []int
+	for _, k := range d.keywords() {
+		if !strings.EqualFold(k.key, key) && !strings.HasPrefix(strings.ToLower(k.key), strings.ToLower(key)+".") {
+			continue
+		}
+		if at < 0 && strings.EqualFold(k.key, key) {
+			at, name = k.line, k.key
+		}
+		drop = append(drop, k.line)
+	}
+	if at < 0 {
+		at = len(trimTrailingBlank(d.lines))
+	}
+
+	var rendered []string
+	switch x := value.(type) {
+	case []string:
+		rendered = orgList(name, x)
+	case []any:
+		items := make([]string, len(x))
+		for i, e := range x {
+			items[i] = orgScalar(e)
+		}
+		rendered = orgList(name, items)
+	default:
+		rendered = []string{"#+" + name + ": " + orgScalar(value)}
+	}
+
+	var out []string
+	for i, l := range d.lines[:at] {
+		if !containsInt(drop, i) {
+			out = append(out, l)
+		}
+	}
+	out = append(out, rendered...)
+	for i, l := range d.lines[at:] {
+		if !containsInt(drop, at+i) {
+			out = append(out, l)
+		}
+	}
+	d.lines = out
+	return nil
+}
+
+// remove deletes key in every spelling, including dotted children.
+func (d *orgDoc) remove(key string) error {
+	lower := strings.ToLower(key)
+	var out []string
+	for _, l := range d.lines {
+		if m := orgKeywordRe.FindStringSubmatch(l); m != nil {
+			k := strings.ToLower(strings.TrimSuffix(m[1], "[]"))
+			if k == lower || strings.HasPrefix(k, lower+".") {
+				continue
+			}
+		}
+		out = append(out, l)
+	}
+	d.lines = out
+	return nil
+}
+
+// upperCase reports whether the existing keywords are written in capitals.
+func (d *orgDoc) upperCase() bool {
+	for _, k := range d.keywords() {
+		return strings.IndexFunc(k.key, unicode.IsLower) < 0
+	}
+	return true
+}
+
+// orgList renders a list as "key[]: a b", or as one repeated keyword per
+// item when an item contains whitespace.
+func orgList(key string, items []string) []string {
+	for _, it := range items {
+		if strings.ContainsAny(it, " \t") {
+			out := make([]string, len(items))
+			for i, it := range items {
+				out[i] = "#+" + key + ": " + it
+			}
+			return out
+		}
+	}
+	return []string{"#+" + key + "[]: " + strings.Join(items, " ")}
+}
+
+// orgNested reports whether v is a map or a list holding one.
+func orgNested(v any) bool {
+	switch x := v.(type) {
+	case map[string]any:
+		return true
+	case []any:
+		for _, e := range x {
+			if orgNested(e) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// orgDateKeys are the keywords whose Org timestamps Hugo reads as dates.
+var orgDateKeys = []string{"date", "lastmod", "modified", "publishdate", "pubdate", "published", "expirydate", "unpublishdate"}
+
+// orgAsYAML rewrites the Org keyword header of f as YAML front matter with
+// the values Hugo reads from it: keys in lower case, timestamps reduced to
+// their date and list keywords as lists. Values stay strings, except dates.
+// Lines of the header that are not keywords are kept at the start of the
+// content.
+func orgAsYAML(f FrontMatter) FrontMatter {
+	d := parseOrg(f.Header)
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	seen := map[string]bool{}
+	keyword := map[int]bool{}
+	for _, k := range d.keywords() {
+		keyword[k.line] = true
+		key := strings.ToLower(k.key)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		v, _ := d.lookup(key)
+		n := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str"}
+		switch x := v.(type) {
+		case []string:
+			n = &yaml.Node{Kind: yaml.SequenceNode}
+			for _, s := range x {
+				n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: s})
+			}
+		case string:
+			n.Value = x
+			if slices.Contains(orgDateKeys, key) {
+				n.Tag = ""
+			}
+		}
+		root.Content = append(root.Content, scalarKey(key), n)
+	}
+	var other []string
+	for i, l := range d.lines {
+		if !keyword[i] {
+			other = append(other, l+f.newline)
+		}
+	}
+
+	var buf bytes.Buffer
+	if len(root.Content) > 0 {
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		_ = enc.Encode(root)
+		_ = enc.Close()
+	}
+	return FrontMatter{
+		Format:  YAML,
+		Header:  strings.TrimSuffix(buf.String(), "\n"),
+		Content: strings.Join(other, "") + f.Content,
+		bom:     f.bom,
+		leading: f.leading,
+		newline: f.newline,
+	}
+}
+
+func orgScalar(v any) string {
+	switch x := v.(type) {
+	case rawValue:
+		return string(x)
+	case string:
+		return x
+	case time.Time:
+		return x.Format(time.RFC3339)
+	}
+	return fmt.Sprint(v)
+}
+
+func containsInt(list []int, v int) bool {
+	i := sort.SearchInts(list, v)
+	return i < len(list) && list[i] == v
+}
@@ -0,0 +1,109 @@
+package fm
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestOrgEdit(t *testing.T) {
+	runEditTests(t, []editTest{
+		{
+			"new keys follow the block's case",
+			"#+TITLE: A\n#+DATE: 2024-06-15\n\n* Heading\n",
+			set("lastmod", rawValue("2025-01-01")),
+			"#+TITLE: A\n#+DATE: 2024-06-15\n#+LASTMOD: 2025-01-01\n\n* Heading\n",
+		},
+		{
+			"lower-case block",
+			"#+title: A\nbody\n",
+			set("lastmod", rawValue("2025-01-01")),
+			"#+title: A\n#+lastmod: 2025-01-01\nbody\n",
+		},
+		{
+			"replaced keyword keeps its place and spelling",
+			"#+Title: A\n#+DATE: 2024-06-15\n",
+			set("title", "B"),
+			"#+Title: B\n#+DATE: 2024-06-15\n",
+		},
+		{
+			"list",
+			"#+TITLE: A\n",
+			setList("revisions_history", "2024-06-15", "2025-01-01"),
+			"#+TITLE: A\n#+REVISIONS_HISTORY[]: 2024-06-15 2025-01-01\n",
+		},
+		{
+			"list items with spaces repeat the keyword",
+			"#+TITLE: A\n",
+			setList("tags", "a b", "c"),
+			"#+TITLE: A\n#+TAGS: a b\n#+TAGS: c\n",
+		},
+		{
+			"list replaces every spelling",
+			"#+tags[]: a\n#+TITLE: A\n#+TAGS[]: b\n",
+			setList("tags", "c"),
+			"#+tags[]: c\n#+TITLE: A\n",
+		},
+		{
+			"remove",
+			"#+TITLE: A\n#+DRAFT: true\n",
+			remove("draft"),
+			"#+TITLE: A\n",
+		},
+		{
+			"a map turns the header into yaml",
+			"#+TITLE: 1984\n#+DATE: <2024-06-15 Sat>\n#+tags[]: a b\n#+OPTIONS: toc:nil\n\n* Heading\n",
+			set("revisions_notes", map[string]any{"2024-06-15": "first"}),
+			"---\ntitle: \"1984\"\ndate: 2024-06-15\ntags:\n  - a\n  - b\noptions: toc:nil\nrevisions_notes:\n  \"2024-06-15\": first\n---\n\n* Heading\n",
+		},
+		{
+			"a dotted path turns the header into yaml",
+			"#+title: A\r\nbody\r\n",
+			set("params.x", "y"),
+			"---\r\ntitle: A\r\nparams:\r\n  x: \"y\"\r\n---\r\nbody\r\n",
+		},
+		{
+			"build options",
+			"#+TITLE: A\n",
+			InjectBuildOptions,
+			"---\ntitle: A\nbuild:\n  list: never\n  render: true\n---\n",
+		},
+		{
+			"dotted keywords are not build options",
+			"#+TITLE: A\n#+build.list: never\n",
+			InjectBuildOptions,
+			"---\ntitle: A\nbuild.list: never\nbuild:\n  list: never\n  render: true\n---\n",
+		},
+	})
+}
+
+func TestOrgDottedKeywordsAreFlat(t *testing.T) {
+	f, err := Parse("#+TITLE: A\n#+build.list: never\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := Get(f, "build.list"); ok {
+		t.Error("build.list read as nested, Hugo reads it as one key")
+	}
+	if got := GetValue(f, "build.list"); got != "never" {
+		t.Errorf("build.list = %q", got)
+	}
+}
+
+func TestOrgGet(t *testing.T) {
+	f, err := Parse("#+TITLE: A\n#+DATE: <2024-06-15 Sat 10:30>\n#+tags[]: a b\n#+alias: x y\n#+alias: z\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := GetValue(f, "title"); got != "A" {
+		t.Errorf("title = %q", got)
+	}
+	if got := GetValue(f, "date"); got != "2024-06-15" {
+		t.Errorf("date of an Org timestamp = %q, want the date", got)
+	}
+	if got := GetList(f, "tags"); !slices.Equal(got, []string{"a", "b"}) {
+		t.Errorf("tags = %q", got)
+	}
+	if got := GetList(f, "alias"); !slices.Equal(got, []string{"x y", "z"}) {
+		t.Errorf("repeated keyword = %q", got)
+	}
+}
@@ -0,0 +1,637 @@
+package fm
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// tomlDoc is a TOML header split into the lines that hold root keys, table
+// headers and the key/value pairs inside each table. Values are edited by
+// replacing only the lines they span; everything else is kept verbatim.
+type tomlDoc struct {
+	lines   []string
+	entries []tomlEntry
+}
+
+type tomlEntryKind int
+
+const (
+	tomlKeyValue tomlEntryKind = iota
+	tomlTable
+	tomlArrayTable
+)
+
+type tomlEntry struct {
+	kind       tomlEntryKind
+	table      []string // enclosing table for key/values, own path for headers
+	inArray    bool     // key/value inside an array of tables
+	key        []string // dotted key of a key/value
+	keyText    string   // key as written, including indentation
+	start, end int      // line range [start, end)
+	raw        string   // raw value text
+	comment    string   // trailing comment on the value's last line
+}
+
+func (e tomlEntry) path() []string {
+	return append(append([]string{}, e.table...), e.key...)
+}
+
+func parseTOML(header string) (*tomlDoc, error) {
+	d := &tomlDoc{lines: splitLines(header)}
+	var table []string
+	inArray := false
+	for i := 0; i < len(d.lines); i++ {
+		t := strings.TrimSpace(d.lines[i])
+		if t == "" || strings.HasPrefix(t, "#") {
+			continue
+		}
+		if strings.HasPrefix(t, "[") {
+			kind := tomlTable
+			inner := t[1:]
+			if strings.HasPrefix(t, "[[") {
+				kind = tomlArrayTable
+				inner = t[2:]
+			}
+			path, rest, err := parseTOMLKey(inner)
+			if err != nil {
+				return nil, fmt.Errorf("toml front matter line %d: %w", i+1, err)
+			}
+			closing := "]"
+			if kind == tomlArrayTable {
+				closing = "]]"
+			}
+			if !strings.HasPrefix(strings.TrimSpace(rest), closing) {
+				return nil, fmt.Errorf("toml front matter line %d: unterminated table header", i+1)
+			}
+			table, inArray = path, kind == tomlArrayTable
+			d.entries = append(d.entries, tomlEntry{kind: kind, table: path, start: i, end: i + 1})
+			continue
+		}
+		eq := keyValueSplit(d.lines[i])
+		if eq < 0 {
+			return nil, fmt.Errorf("toml front matter line %d: expected key = value", i+1)
+		}
+		key, _, err := parseTOMLKey(d.lines[i][:eq])
+		if err != nil {
+			return nil, fmt.Errorf("toml front matter line %d: %w", i+1, err)
+		}
+		end, raw, comment := scanTOMLValue(d.lines, i, eq+1)
+		d.entries = append(d.entries, tomlEntry{
+			kind:    tomlKeyValue,
+			table:   table,
+			inArray: inArray,
+			key:     key,
+			keyText: strings.TrimRight(d.lines[i][:eq], " \t"),
+			start:   i,
+			end:     end + 1,
+			raw:     raw,
+			comment: comment,
+		})
+		i = end
+	}
+	return d, nil
+}
+
+func (d *tomlDoc) String() string {
+	if len(d.lines) == 0 {
+		return ""
+	}
+	return strings.Join(d.lines, "\n") + "\n"
+}
+
+// find returns the key/value entry whose full path is path.
+func (d *tomlDoc) find(path []string) (tomlEntry, bool) {
+	for _, e := range d.entries {
+		if e.kind == tomlKeyValue && !e.inArray && equalPath(e.path(), path) {
+			return e, true
+		}
+	}
+	return tomlEntry{}, false
+}
+
+// findTable returns the index of the [table] header for path, or -1.
+func (d *tomlDoc) findTable(path []string) int {
+	for i, e := range d.entries {
+		if e.kind == tomlTable && equalPath(e.table, path) {
+			return i
+		}
+	}
+	return -1
+}
+
+// has reports whether path is defined in any form: as a key, a table, a
+// dotted key prefix or an array of tables.
+func (d *tomlDoc) has(path []string) bool {
+	for _, e := range d.entries {
+		p := e.table
+		if e.kind == tomlKeyValue {
+			if e.inArray {
+				continue
+			}
+			p = e.path()
+		}
+		if len(p) >= len(path) && equalPath(p[:len(path)], path) {
+			return true
+		}
+	}
+	return false
+}
+
+// decode unmarshals the whole header for typed reads.
+func (d *tomlDoc) decode() (map[string]any, error) {
+	m := map[string]any{}
+	if err := toml.Unmarshal([]byte(d.String()), &m); err != nil {
+		return nil, fmt.Errorf("parse toml front matter: %w", err)
+	}
+	return m, nil
+}
+
+// set writes value at path. Root keys are always placed before the first
+// table; keys of an existing [table] go inside it; a map stored at a root
+// key becomes a table of its own at the end of the header.
+func (d *tomlDoc) set(path []string, value any) error {
+	if e, ok := d.find(path); ok {
+		line := e.keyText + " = " + tomlValueLike(value, e.raw)
+		if e.comment != "" {
+			line += " " + e.comment
+		}
+		d.replace(e.start, e.end, splitLines(line))
+		return d.reparse()
+	}
+
+	// An inline table somewhere along the path is rewritten as a whole.
+	for n := len(path) - 1; n > 0; n-- {
+		e, ok := d.find(path[:n])
+		if !ok {
+			continue
+		}
+		if !strings.HasPrefix(strings.TrimSpace(e.raw), "{") {
+			return fmt.Errorf("toml front matter: %s is not a table", strings.Join(path[:n], "."))
+		}
+		var holder struct{ V map[string]any }
+		if err := toml.Unmarshal([]byte("V = "+e.raw), &holder); err != nil {
+			return fmt.Errorf("toml front matter: %w", err)
+		}
+		setNested(holder.V, path[n:], value)
+		return d.set(path[:n], holder.V)
+	}
+
+	// Longest existing [table] that is a prefix of the key's parent.
+	for n := len(path) - 1; n > 0; n-- {
+		if ti := d.findTable(path[:n]); ti >= 0 {
+			line := renderTOMLKey(path[n:]) + " = " + tomlValue(value)
+			d.insert(d.tableEnd(ti), []string{line})
+			return d.reparse()
+		}
+	}
+
+	if len(path) > 1 && d.has(path[:len(path)-1]) {
+		// The parent only exists through dotted root keys; extend those.
+		d.insert(d.rootEnd(), []string{renderTOMLKey(path) + " = " + tomlValue(value)})
+		return d.reparse()
+	}
+	if m, ok := value.(map[string]any); ok && len(path) == 1 {
+		// A table stored again replaces the one already there
+		if d.findTable(path) >= 0 {
+			if err := d.remove(path); err != nil {
+				return err
+			}
+		}
+		d.appendTable(path, m)
+		return d.reparse()
+	}
+	if len(path) > 1 {
+		d.appendTable(path[:len(path)-1], map[string]any{path[len(path)-1]: value})
+		return d.reparse()
+	}
+
+	d.insert(d.rootEnd(), []string{renderTOMLKey(path) + " = " + tomlValue(value)})
+	return d.reparse()
+}
+
+// remove deletes a key, a dotted key or a whole table.
+func (d *tomlDoc) remove(path []string) error {
+	if e, ok := d.find(path); ok {
+		d.replace(e.start, e.end, nil)
+		return d.reparse()
+	}
+	if ti := d.findTable(path); ti >= 0 {
+		start := d.commentStart(d.entries[ti].start)
+		if start > 0 && strings.TrimSpace(d.lines[start-1]) == "" {
+			start--
+		}
+		d.replace(start, d.tableEnd(ti), nil)
+		return d.reparse()
+	}
+	for n := len(path) - 1; n > 0; n-- {
+		e, ok := d.find(path[:n])
+		if !ok || !strings.HasPrefix(strings.TrimSpace(e.raw), "{") {
+			continue
+		}
+		var holder struct{ V map[string]any }
+		if err := toml.Unmarshal([]byte("V = "+e.raw), &holder); err != nil {
+			return fmt.Errorf("toml front matter: %w", err)
+		}
+		deleteNested(holder.V, path[n:])
+		return d.set(path[:n], holder.V)
+	}
+	return nil
+}
+
+// rootEnd is the line where a new root key goes: after the last root key,
+// or before the first table header and the comments directly above it.
+func (d *tomlDoc) rootEnd() int {
+	last := -1
+	for _, e := range d.entries {
+		if e.kind != tomlKeyValue {
+			break
+		}
+		last = e.end
+	}
+	if last >= 0 {
+		return last
+	}
+	for _, e := range d.entries {
+		if e.kind != tomlKeyValue {
+			return d.commentStart(e.start)
+		}
+	}
+	return len(trimTrailingBlank(d.lines))
+}
+
+// tableEnd is the line after the last key of the table whose header is
+// entry ti, or right after the header when the table is empty.
+func (d *tomlDoc) tableEnd(ti int) int {
+	end := d.entries[ti].end
+	for _, e := range d.entries[ti+1:] {
+		if e.kind != tomlKeyValue {
+			break
+		}
+		end = e.end
+	}
+	return end
+}
+
+// commentStart walks back from line i over the comment block attached to it.
+func (d *tomlDoc) commentStart(i int) int {
+	for i > 0 && strings.HasPrefix(strings.TrimSpace(d.lines[i-1]), "#") {
+		i--
+	}
+	return i
+}
+
+func (d *tomlDoc) appendTable(path []string, m map[string]any) {
+	lines := trimTrailingBlank(d.lines)
+	if len(lines) > 0 {
+		lines = append(lines, "")
+	}
+	lines = append(lines, "["+renderTOMLKey(path)+"]")
+	for _, k := range sortedKeys(m) {
+		lines = append(lines, renderTOMLKey([]string{k})+" = "+tomlValue(m[k]))
+	}
+	d.lines = lines
+}
+
+func (d *tomlDoc) insert(at int, lines []string) {
+	d.replace(at, at, lines)
+}
+
+func (d *tomlDoc) replace(start, end int, lines []string) {
+	out := append([]string{}, d.lines[:start]...)
+	out = append(out, lines...)
+	d.lines = append(out, d.lines[end:]...)
+}
+
+func (d *tomlDoc) reparse() error {
+	nd, err := parseTOML(d.String())
+	if err != nil {
+		return err
+	}
+	*d = *nd
+	return nil
+}
+
+// keyValueSplit returns the index of the '=' that separates key and value,
+// skipping over quoted keys, or -1.
+func keyValueSplit(line string) int {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '=':
+			return i
+		case c == '#':
+			return -1
+		}
+	}
+	return -1
+}
+
+// parseTOMLKey reads a (possibly dotted, possibly quoted) key from the start
+// of s and returns its parts and the unread remainder.
+func parseTOMLKey(s string) ([]string, string, error) {
+	var parts []string
+	i := 0
+	for {
+		for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+			i++
+		}
+		if i >= len(s) {
+			break
+		}
+		switch s[i] {
+		case '"':
+			j := i + 1
+			var b strings.Builder
+			for j < len(s) && s[j] != '"' {
+				if s[j] == '\\' && j+1 < len(s) {
+					j++
+				}
+				b.WriteByte(s[j])
+				j++
+			}
+			if j >= len(s) {
+				return nil, "", fmt.Errorf("unterminated quoted key")
+			}
+			parts = append(parts, b.String())
+			i = j + 1
+		case '\'':
+			j := strings.IndexByte(s[i+1:], '\'')
+			if j < 0 {
+				return nil, "", fmt.Errorf("unterminated quoted key")
+			}
+			parts = append(parts, s[i+1:i+1+j])
+			i = i + 2 + j
+		default:
+			j := i
+			for j < len(s) && isBareKeyChar(s[j]) {
+				j++
+			}
+			if j == i {
+				return nil, "", fmt.Errorf("invalid key %q", strings.TrimSpace(s))
+			}
+			parts = append(parts, s[i:j])
+			i = j
+		}
+		for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+			i++
+		}
+		if i < len(s) && s[i] == '.' {
+			i++
+			continue
+		}
+		break
+	}
+	if len(parts) == 0 {
+		return nil, "", fmt.Errorf("empty key")
+	}
+	return parts, s[i:], nil
+}
+
+func isBareKeyChar(c byte) bool {
+	return c == '_' || c == '-' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// scanTOMLValue scans a value that starts at column col of lines[i] and may
+// continue over several lines (multi-line strings, arrays, inline tables).
+// It returns the index of the value's last line, the value text and any
+// trailing comment.
+func scanTOMLValue(lines []string, i, col int) (int, string, string) {
+	var (
+		raw     strings.Builder
+		depth   int
+		mlQuote string // """ or ''' while inside a multi-line string
+	)
+	for j := i; j < len(lines); j++ {
+		line := lines[j]
+		pos := 0
+		if j == i {
+			pos = col
+		}
+		var quote byte
+		comment := ""
+		k := pos
+		for ; k < len(line); k++ {
+			c := line[k]
+			if mlQuote != "" {
+				if c == '\\' && mlQuote == `"""` {
+					k++
+				} else if strings.HasPrefix(line[k:], mlQuote) {
+					k += 2
+					mlQuote = ""
+				}
+				continue
+			}
+			if quote != 0 {
+				if c == '\\' && quote == '"' {
+					k++
+				} else if c == quote {
+					quote = 0
+				}
+				continue
+			}
+			switch {
+			case strings.HasPrefix(line[k:], `"""`), strings.HasPrefix(line[k:], `'''`):
+				mlQuote = line[k : k+3]
+				k += 2
+			case c == '"' || c == '\'':
+				quote = c
+			case c == '[' || c == '{':
+				depth++
+			case c == ']' || c == '}':
+				depth--
+			case c == '#':
+				comment = line[k:]
+			}
+			if comment != "" {
+				break
+			}
+		}
+		if j > i {
+			raw.WriteByte('\n')
+		}
+		if comment != "" && depth == 0 && mlQuote == "" {
+			raw.WriteString(line[pos:k])
+			return j, strings.TrimSpace(raw.String()), comment
+		}
+		raw.WriteString(line[pos:])
+		if depth <= 0 && mlQuote == "" {
+			return j, strings.TrimSpace(raw.String()), ""
+		}
+	}
+	return len(lines) - 1, strings.TrimSpace(raw.String()), ""
+}
+
+func renderTOMLKey(path []string) string {
+	parts := make([]string, len(path))
+	for i, p := range path {
+		bare := p != ""
+		for j := 0; j < len(p); j++ {
+			if !isBareKeyChar(p[j]) {
+				bare = false
+				break
+			}
+		}
+		if bare {
+			parts[i] = p
+		} else {
+			parts[i] = tomlQuote(p)
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// tomlQuote renders s as a TOML basic string.
+func tomlQuote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// tomlValue renders a Go value as TOML. Maps become inline tables.
+func tomlValue(v any) string {
+	switch x := v.(type) {
+	case rawValue:
+		return string(x)
+	case string:
+		return tomlQuote(x)
+	case bool, int, int64, float64:
+		return fmt.Sprint(x)
+	case time.Time:
+		return x.Format(time.RFC3339)
+	case []string:
+		items := make([]string, len(x))
+		for i, s := range x {
+			items[i] = tomlQuote(s)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case []any:
+		items := make([]string, len(x))
+		for i, e := range x {
+			items[i] = tomlValue(e)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case map[string]any:
+		if len(x) == 0 {
+			return "{}"
+		}
+		items := make([]string, 0, len(x))
+		for _, k := range sortedKeys(x) {
+			items = append(items, renderTOMLKey([]string{k})+" = "+tomlValue(x[k]))
+		}
+		return "{ " + strings.Join(items, ", ") + " }"
+	}
+	return tomlQuote(fmt.Sprint(v))
+}
+
+// tomlValueLike renders v following the layout of the value it replaces:
+// literal strings stay literal and multi-line arrays stay one item per line.
+func tomlValueLike(v any, old string) string {
+	switch x := v.(type) {
+	case string:
+		if strings.HasPrefix(old, "'") && !strings.HasPrefix(old, "'''") &&
+			!strings.ContainsAny(x, "'\n\r") {
+			return "'" + x + "'"
+		}
+	case []string:
+		if strings.HasPrefix(old, "[") && strings.Contains(old, "\n") {
+			indent := "  "
+			if lines := strings.Split(old, "\n"); len(lines) > 1 {
+				if l := lines[1]; strings.TrimSpace(l) != "]" {
+					indent = l[:indentOf(l)]
+				}
+			}
+			var b strings.Builder
+			b.WriteString("[\n")
+			for _, s := range x {
+				b.WriteString(indent + tomlQuote(s) + ",\n")
+			}
+			b.WriteString("]")
+			return b.String()
+		}
+	}
+	return tomlValue(v)
+}
+
+// tomlScalarString converts a decoded TOML value to the string GetValue
+// returns: strings as-is, dates and times in their TOML form.
+func tomlScalarString(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case time.Time:
+		return x.Format(time.RFC3339)
+	case map[string]any, []any:
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+func equalPath(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func setNested(m map[string]any, path []string, v any) {
+	for _, k := range path[:len(path)-1] {
+		next, ok := m[k].(map[string]any)
+		if !ok {
+			next = map[string]any{}
+			m[k] = next
+		}
+		m = next
+	}
+	m[path[len(path)-1]] = v
+}
+
+func deleteNested(m map[string]any, path []string) {
+	for _, k := range path[:len(path)-1] {
+		next, ok := m[k].(map[string]any)
+		if !ok {
+			return
+		}
+		m = next
+	}
+	delete(m, path[len(path)-1])
+}
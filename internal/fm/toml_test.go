@@ -0,0 +1,121 @@
+package fm
+
+import "testing"
+
+func TestTOMLEdit(t *testing.T) {
+	runEditTests(t, []editTest{
+		{
+			"root keys stay above tables",
+			"+++\ntitle = 'A'\n\n# page params\n[params]\nx = 1\n+++\nbody\n",
+			set("lastmod", rawValue("2025-01-01")),
+			"+++\ntitle = 'A'\nlastmod = 2025-01-01\n\n# page params\n[params]\nx = 1\n+++\nbody\n",
+		},
+		{
+			"root key before the first table when there are none",
+			"+++\n# page params\n[params]\nx = 1\n+++\n",
+			set("title", "A"),
+			"+++\ntitle = \"A\"\n# page params\n[params]\nx = 1\n+++\n",
+		},
+		{
+			"replaced value keeps its comment and quoting",
+			"+++\ntitle = 'A' # short\n+++\n",
+			set("title", "B"),
+			"+++\ntitle = 'B' # short\n+++\n",
+		},
+		{
+			"key inside an existing table",
+			"+++\n[params]\nx = 1\n\n[build]\nlist = 'never'\n+++\n",
+			set("params.y", "z"),
+			"+++\n[params]\nx = 1\ny = \"z\"\n\n[build]\nlist = 'never'\n+++\n",
+		},
+		{
+			"dotted root keys are extended",
+			"+++\nparams.x = 1\n+++\n",
+			set("params.y", 2),
+			"+++\nparams.x = 1\nparams.y = 2\n+++\n",
+		},
+		{
+			"inline table",
+			"+++\nparams = {x = 1}\n+++\n",
+			set("params.y", 2),
+			"+++\nparams = { x = 1, y = 2 }\n+++\n",
+		},
+		{
+			"multi-line array stays multi-line",
+			"+++\nh = [\n  'a',\n  'b', # second\n]\ndraft = false\n+++\n",
+			setList("h", "a", "b", "c"),
+			"+++\nh = [\n  \"a\",\n  \"b\",\n  \"c\",\n]\ndraft = false\n+++\n",
+		},
+		{
+			"arrays of tables are left alone",
+			"+++\ntitle = 'A'\n\n[[menus.main]]\nname = 'x'\n\n[[menus.main]]\nname = 'y'\n+++\n",
+			set("lastmod", rawValue("2025-01-01")),
+			"+++\ntitle = 'A'\nlastmod = 2025-01-01\n\n[[menus.main]]\nname = 'x'\n\n[[menus.main]]\nname = 'y'\n+++\n",
+		},
+		{
+			"a key named like one in an array of tables is a root key",
+			"+++\n[[menus.main]]\nname = 'x'\n+++\n",
+			set("name", "A"),
+			"+++\nname = \"A\"\n[[menus.main]]\nname = 'x'\n+++\n",
+		},
+		{
+			"map becomes a table at the end",
+			"+++\ntitle = 'A'\n+++\n",
+			set("revisions_notes", map[string]any{"2025-01-01": "first"}),
+			"+++\ntitle = 'A'\n\n[revisions_notes]\n2025-01-01 = \"first\"\n+++\n",
+		},
+		{
+			"map replaces its table",
+			"+++\ntitle = 'A'\n\n[revisions_notes]\nv1 = 'old'\n\n[params]\nx = 1\n+++\n",
+			set("revisions_notes", map[string]any{"v2": "new"}),
+			"+++\ntitle = 'A'\n\n[params]\nx = 1\n\n[revisions_notes]\nv2 = \"new\"\n+++\n",
+		},
+		{
+			"remove a table with its comment",
+			"+++\ntitle = 'A'\n\n# build options\n[build]\nlist = 'never'\n\n[params]\nx = 1\n+++\n",
+			remove("build"),
+			"+++\ntitle = 'A'\n\n[params]\nx = 1\n+++\n",
+		},
+		{
+			"remove a multi-line value",
+			"+++\nh = [\n  'a',\n]\ntitle = 'A'\n+++\n",
+			remove("h"),
+			"+++\ntitle = 'A'\n+++\n",
+		},
+		{
+			"remove from an inline table",
+			"+++\nparams = {x = 1, y = 2}\n+++\n",
+			remove("params.x"),
+			"+++\nparams = { y = 2 }\n+++\n",
+		},
+	})
+}
+
+func TestTOMLSetScalarParent(t *testing.T) {
+	f, err := Parse("+++\nparams = 'x'\n+++\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Set(f, "params.y", 1); err == nil {
+		t.Error("Set under a string: want an error")
+	}
+}
+
+func TestTOMLGet(t *testing.T) {
+	f, err := Parse("+++\ndate = 2024-06-15\ntags = [\n  'a',\n  'b',\n]\n\n[params]\nauthor = 'X'\n\n[[menus.main]]\nname = 'x'\n+++\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := Get(f, "params.author"); !ok || v.String() != "X" {
+		t.Errorf("params.author = %q, %v", v.String(), ok)
+	}
+	if v, ok := Get(f, "date"); !ok || v.Kind != TimeKind {
+		t.Errorf("date = %v, want a time", v.Kind)
+	}
+	if v, ok := Get(f, "menus.main"); !ok || v.Kind != ListKind || len(v.List()) != 1 {
+		t.Errorf("menus.main = %v, want a list of one table", v.Kind)
+	}
+	if got := GetList(f, "tags"); len(got) != 2 {
+		t.Errorf("tags = %q", got)
+	}
+}
@@ -0,0 +1,501 @@
+package fm
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// Kind tags the type of a front matter Value.
+type Kind int
+
+const (
+	NullKind Kind = iota
+	StringKind
+	BoolKind
+	IntKind
+	FloatKind
+	TimeKind
+	ListKind
+	MapKind
+)
+
+func (k Kind) String() string {
+	switch k {
+	case StringKind:
+		return "string"
+	case BoolKind:
+		return "bool"
+	case IntKind:
+		return "int"
+	case FloatKind:
+		return "float"
+	case TimeKind:
+		return "time"
+	case ListKind:
+		return "list"
+	case MapKind:
+		return "map"
+	}
+	return "null"
+}
+
+// floatingTime is a date or time written without an offset. Decoders
+// report it in UTC; it means the site's time zone, as Hugo reads it.
+type floatingTime time.Time
+
+// hasOffset reports whether the date or time s ends in an offset or Z.
+func hasOffset(s string) bool {
+	s = strings.TrimSpace(s)
+	return len(s) > len("2006-01-02") && strings.ContainsAny(s[len("2006-01-02"):], "+-Zz")
+}
+
+// Value is a front matter value together with its kind. Values are read
+// with the format's own typing rules: a YAML timestamp or a TOML date is a
+// TimeKind, a JSON number an IntKind or FloatKind, and so on.
+type Value struct {
+	Kind Kind
+	v    any
+}
+
+// String returns scalars as text (times in RFC 3339) and "" for lists and maps.
+func (v Value) String() string {
+	switch v.Kind {
+	case StringKind:
+		return v.v.(string)
+	case BoolKind:
+		return strconv.FormatBool(v.v.(bool))
+	case IntKind:
+		return strconv.FormatInt(v.v.(int64), 10)
+	case FloatKind:
+		return strconv.FormatFloat(v.v.(float64), 'f', -1, 64)
+	case TimeKind:
+		t, _ := v.time()
+		return t.Format(time.RFC3339)
+	}
+	return ""
+}
+
+// time returns a TimeKind value's time and whether it was written without
+// an offset.
+func (v Value) time() (time.Time, bool) {
+	if t, ok := v.v.(floatingTime); ok {
+		return time.Time(t), true
+	}
+	return v.v.(time.Time), false
+}
+
+// Bool returns the value as a boolean; "true" and "false" strings count.
+func (v Value) Bool() (bool, bool) {
+	switch v.Kind {
+	case BoolKind:
+		return v.v.(bool), true
+	case StringKind:
+		b, err := strconv.ParseBool(v.v.(string))
+		return b, err == nil
+	}
+	return false, false
+}
+
+// Int returns the value as an integer.
+func (v Value) Int() (int64, bool) {
+	switch v.Kind {
+	case IntKind:
+		return v.v.(int64), true
+	case FloatKind:
+		return int64(v.v.(float64)), true
+	case StringKind:
+		i, err := strconv.ParseInt(v.v.(string), 10, 64)
+		return i, err == nil
+	}
+	return 0, false
+}
+
+// Time returns the value as a time; strings in the usual front matter date
+// layouts are parsed.
+func (v Value) Time() (time.Time, bool) {
+	return v.TimeIn(time.UTC)
+}
+
+// TimeIn is Time for dates written without an offset, which are read in
+// loc as Hugo reads them in the site's time zone. Dates with an offset,
+// Z included, keep it.
+func (v Value) TimeIn(loc *time.Location) (time.Time, bool) {
+	switch v.Kind {
+	case TimeKind:
+		t, floating := v.time()
+		if floating {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+		}
+		return t, true
+	case StringKind:
+		t, err := ParseTimeIn(v.v.(string), loc)
+		return t, err == nil
+	}
+	return time.Time{}, false
+}
+
+// List returns the elements of a list value.
+func (v Value) List() []Value {
+	if v.Kind != ListKind {
+		return nil
+	}
+	return v.v.([]Value)
+}
+
+// Map returns the members of a map value.
+func (v Value) Map() map[string]Value {
+	if v.Kind != MapKind {
+		return nil
+	}
+	return v.v.(map[string]Value)
+}
+
+// Interface returns the value as plain Go data: string, bool, int64,
+// float64, time.Time, []any, map[string]any or nil.
+func (v Value) Interface() any {
+	switch v.Kind {
+	case ListKind:
+		out := make([]any, 0, len(v.List()))
+		for _, e := range v.List() {
+			out = append(out, e.Interface())
+		}
+		return out
+	case MapKind:
+		out := make(map[string]any, len(v.Map()))
+		for k, e := range v.Map() {
+			out[k] = e.Interface()
+		}
+		return out
+	case TimeKind:
+		t, _ := v.time()
+		return t
+	}
+	return v.v
+}
+
+// valueOf converts decoded YAML/TOML/JSON data into a Value.
+func valueOf(x any) Value {
+	switch t := x.(type) {
+	case nil:
+		return Value{}
+	case Value:
+		return t
+	case string:
+		return Value{StringKind, t}
+	case bool:
+		return Value{BoolKind, t}
+	case int:
+		return Value{IntKind, int64(t)}
+	case int64:
+		return Value{IntKind, t}
+	case uint64:
+		return Value{IntKind, int64(t)}
+	case float64:
+		return Value{FloatKind, t}
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return Value{IntKind, i}
+		}
+		f, _ := t.Float64()
+		return Value{FloatKind, f}
+	case time.Time, floatingTime:
+		return Value{TimeKind, t}
+	case toml.LocalDate:
+		return Value{TimeKind, floatingTime(t.AsTime(time.UTC))}
+	case toml.LocalDateTime:
+		return Value{TimeKind, floatingTime(t.AsTime(time.UTC))}
+	case toml.LocalTime:
+		return Value{StringKind, t.String()}
+	case []string:
+		out := make([]Value, len(t))
+		for i, e := range t {
+			out[i] = Value{StringKind, e}
+		}
+		return Value{ListKind, out}
+	case []any:
+		out := make([]Value, len(t))
+		for i, e := range t {
+			out[i] = valueOf(e)
+		}
+		return Value{ListKind, out}
+	case map[string]any:
+		out := make(map[string]Value, len(t))
+		for k, e := range t {
+			out[k] = valueOf(e)
+		}
+		return Value{MapKind, out}
+	}
+	return Value{StringKind, fmt.Sprint(x)}
+}
+
+// goValue turns a Value (or anything else) into data the editors can write.
+func goValue(x any) any {
+	switch t := x.(type) {
+	case Value:
+		return t.Interface()
+	case []Value:
+		out := make([]any, len(t))
+		for i, e := range t {
+			out[i] = e.Interface()
+		}
+		return out
+	case map[string]Value:
+		out := make(map[string]any, len(t))
+		for k, e := range t {
+			out[k] = e.Interface()
+		}
+		return out
+	case int64:
+		return int(t)
+	}
+	return x
+}
+
+// splitPath splits a dotted path such as "params.author".
+func splitPath(path string) []string {
+	return strings.Split(path, ".")
+}
+
+// Get returns the value at a dotted path such as "params.author".
+func Get(f FrontMatter, path string) (Value, bool) {
+	keys := splitPath(path)
+	switch f.Format {
+	case YAML:
+		d, err := parseYAML(f.Header)
+		if err != nil {
+			return Value{}, false
+		}
+		n, ok := d.lookup(keys)
+		if !ok {
+			return Value{}, false
+		}
+		x, err := yamlData(n)
+		if err != nil {
+			return Value{}, false
+		}
+		return valueOf(x), true
+	case TOML:
+		d, err := parseTOML(f.Header)
+		if err != nil {
+			return Value{}, false
+		}
+		m, err := d.decode()
+		if err != nil {
+			return Value{}, false
+		}
+		return walkMap(m, keys)
+	case JSON:
+		d, err := parseJSON(f.Header)
+		if err != nil {
+			return Value{}, false
+		}
+		m, err := d.decode()
+		if err != nil {
+			return Value{}, false
+		}
+		return walkMap(m, keys)
+	case Org:
+		return walkMap(parseOrg(f.Header).decode(), keys)
+	}
+	return Value{}, false
+}
+
+// Values returns every top-level front matter value.
+func Values(f FrontMatter) map[string]Value {
+	m, ok := decodeAll(f)
+	if !ok {
+		return nil
+	}
+	return valueOf(m).Map()
+}
+
+// decodeAll decodes the whole front matter into plain Go data.
+func decodeAll(f FrontMatter) (map[string]any, bool) {
+	var m map[string]any
+	switch f.Format {
+	case YAML:
+		d, err := parseYAML(f.Header)
+		if err != nil || d.root == nil {
+			return nil, false
+		}
+		x, err := yamlData(d.root)
+		if err != nil {
+			return nil, false
+		}
+		var ok bool
+		if m, ok = x.(map[string]any); !ok {
+			return nil, false
+		}
+	case TOML:
+		d, err := parseTOML(f.Header)
+		if err != nil {
+			return nil, false
+		}
+		if m, err = d.decode(); err != nil {
+			return nil, false
+		}
+	case JSON:
+		d, err := parseJSON(f.Header)
+		if err != nil {
+			return nil, false
+		}
+		if m, err = d.decode(); err != nil {
+			return nil, false
+		}
+	case Org:
+		m = parseOrg(f.Header).decode()
+	default:
+		return nil, false
+	}
+	return m, true
+}
+
+// GetFold is Get with keys matched case-insensitively, the way Hugo
+// matches front matter field names.
+func GetFold(f FrontMatter, path string) (Value, bool) {
+	if v, ok := Get(f, path); ok {
+		return v, true
+	}
+	m, ok := decodeAll(f)
+	if !ok {
+		return Value{}, false
+	}
+	var cur any = m
+	for _, k := range splitPath(path) {
+		mm, ok := cur.(map[string]any)
+		if !ok {
+			return Value{}, false
+		}
+		found := false
+		for key, v := range mm {
+			if strings.EqualFold(key, k) {
+				cur, found = v, true
+				break
+			}
+		}
+		if !found {
+			return Value{}, false
+		}
+	}
+	return valueOf(cur), true
+}
+
+func walkMap(m map[string]any, keys []string) (Value, bool) {
+	var cur any = m
+	for _, k := range keys {
+		mm, ok := cur.(map[string]any)
+		if !ok {
+			return Value{}, false
+		}
+		if cur, ok = mm[k]; !ok {
+			return Value{}, false
+		}
+	}
+	return valueOf(cur), true
+}
+
+// GetTime returns the value at path as a time.
+func GetTime(f FrontMatter, path string) (time.Time, bool) {
+	v, ok := Get(f, path)
+	if !ok {
+		return time.Time{}, false
+	}
+	return v.Time()
+}
+
+// GetBool returns the value at path as a boolean.
+func GetBool(f FrontMatter, path string) (bool, bool) {
+	v, ok := Get(f, path)
+	if !ok {
+		return false, false
+	}
+	return v.Bool()
+}
+
+// GetMap returns the map at path.
+func GetMap(f FrontMatter, path string) (map[string]Value, bool) {
+	v, ok := Get(f, path)
+	if !ok || v.Kind != MapKind {
+		return nil, false
+	}
+	return v.Map(), true
+}
+
+// Set stores v at a dotted path, creating intermediate maps. v may be a
+// Value or plain Go data (string, bool, int, float64, time.Time, []string,
+// []any, map[string]any).
+func Set(f FrontMatter, path string, v any) (FrontMatter, error) {
+	return setPath(f, splitPath(path), goValue(v))
+}
+
+// Delete removes the value at a dotted path. A missing path is not an error.
+func Delete(f FrontMatter, path string) (FrontMatter, error) {
+	keys := splitPath(path)
+	switch f.Format {
+	case YAML:
+		d, err := parseYAML(f.Header)
+		if err != nil {
+			return f, err
+		}
+		if err := d.remove(keys); err != nil {
+			return f, err
+		}
+		f.Header = d.String()
+	case TOML:
+		d, err := parseTOML(f.Header)
+		if err != nil {
+			return f, err
+		}
+		if err := d.remove(keys); err != nil {
+			return f, err
+		}
+		f.Header = d.String()
+	case JSON:
+		d, err := parseJSON(f.Header)
+		if err != nil {
+			return f, err
+		}
+		if err := d.remove(keys); err != nil {
+			return f, err
+		}
+		f.Header = d.String()
+	case Org:
+		d := parseOrg(f.Header)
+		if err := d.remove(path); err != nil {
+			return f, err
+		}
+		f.Header = d.String()
+	}
+	return f, nil
+}
+
+// ParseTime parses the date layouts Hugo accepts in front matter.
+func ParseTime(s string) (time.Time, error) {
+	return ParseTimeIn(s, time.UTC)
+}
+
+// ParseTimeIn is ParseTime with dates without an offset read in loc.
+func ParseTimeIn(s string, loc *time.Location) (time.Time, error) {
+	layouts := []string{
+		time.RFC3339Nano,
+		"2006-01-02T15:04:05Z07:00",
+		"2006-01-02 15:04:05 -0700",
+		"2006-01-02 15:04:05Z07:00",
+		"2006-01-02T15:04:05",
+		"2006-01-02 15:04:05",
+		"2006-01-02T15:04",
+		"2006-01-02",
+	}
+	s = strings.TrimSpace(s)
+	for _, l := range layouts {
+		if t, err := time.ParseInLocation(l, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unable to parse date: %s", s)
+}
@@ -0,0 +1,97 @@
+package fm
+
+import (
+	"testing"
+	"time"
+)
+
+// Dates without an offset are read in the site's zone; those with one,
+// Z included, keep it.
+func TestTimeIn(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"yaml date", "---\ndate: 2024-06-15\n---\n", "2024-06-15T00:00:00+08:00"},
+		{"yaml local time", "---\ndate: 2024-06-15T10:30:00\n---\n", "2024-06-15T10:30:00+08:00"},
+		{"yaml space separated", "---\ndate: 2024-06-15 10:30:00\n---\n", "2024-06-15T10:30:00+08:00"},
+		{"yaml utc", "---\ndate: 2024-06-15T10:30:00Z\n---\n", "2024-06-15T10:30:00Z"},
+		{"yaml offset", "---\ndate: 2024-06-15T10:30:00-05:00\n---\n", "2024-06-15T10:30:00-05:00"},
+		{"yaml quoted", "---\ndate: \"2024-06-15T10:30:00\"\n---\n", "2024-06-15T10:30:00+08:00"},
+		{"yaml anchor", "---\na: &d 2024-06-15T10:30:00\ndate: *d\n---\n", "2024-06-15T10:30:00+08:00"},
+		{"toml local date", "+++\ndate = 2024-06-15\n+++\n", "2024-06-15T00:00:00+08:00"},
+		{"toml local datetime", "+++\ndate = 2024-06-15T10:30:00\n+++\n", "2024-06-15T10:30:00+08:00"},
+		{"toml utc", "+++\ndate = 2024-06-15T10:30:00Z\n+++\n", "2024-06-15T10:30:00Z"},
+		{"toml offset", "+++\ndate = 2024-06-15T10:30:00+02:00\n+++\n", "2024-06-15T10:30:00+02:00"},
+		{"json local", "{\"date\": \"2024-06-15T10:30:00\"}\n", "2024-06-15T10:30:00+08:00"},
+		{"json utc", "{\"date\": \"2024-06-15T10:30:00Z\"}\n", "2024-06-15T10:30:00Z"},
+		{"org date", "#+DATE: 2024-06-15\n", "2024-06-15T00:00:00+08:00"},
+		{"org utc", "#+DATE: 2024-06-15T10:30:00Z\n", "2024-06-15T10:30:00Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			v, ok := GetFold(f, "date")
+			if !ok {
+				t.Fatal("GetFold: date not found")
+			}
+			got, ok := v.TimeIn(loc)
+			if !ok {
+				t.Fatalf("TimeIn: %v is not a time", v)
+			}
+			if s := got.Format(time.RFC3339); s != tt.want {
+				t.Errorf("TimeIn = %s, want %s", s, tt.want)
+			}
+		})
+	}
+}
+
+// A merged map keeps the floating times of the map it merges.
+func TestYAMLMergeTimes(t *testing.T) {
+	f, err := Parse("---\nbase: &b\n  date: 2024-06-15T10:30:00\n  title: A\nx:\n  <<: *b\n  title: B\n---\n")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	x := Values(f)["x"].Map()
+	if got := x["title"].String(); got != "B" {
+		t.Errorf("title = %q, want B", got)
+	}
+	got, ok := x["date"].TimeIn(time.FixedZone("CST", 8*3600))
+	if want := "2024-06-15T10:30:00+08:00"; !ok || got.Format(time.RFC3339) != want {
+		t.Errorf("date = %v, want %s", got, want)
+	}
+}
+
+// Times read from a page are written back as times.
+func TestTimeInterface(t *testing.T) {
+	f, err := Parse("+++\ndate = 2024-06-15\n+++\n")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	v, _ := Get(f, "date")
+	if _, ok := v.Interface().(time.Time); !ok {
+		t.Errorf("Interface = %T, want time.Time", v.Interface())
+	}
+	if got, want := v.String(), "2024-06-15T00:00:00Z"; got != want {
+		t.Errorf("String = %q, want %q", got, want)
+	}
+}
+
+// A merge of anything but maps is an error, as in yaml.v3's decoder.
+func TestYAMLMergeNotMap(t *testing.T) {
+	f, err := Parse("---\nx:\n  <<: 5\n---\n")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if _, ok := Get(f, "x"); ok {
+		t.Error("Get: want no value")
+	}
+	if Values(f) != nil {
+		t.Error("Values: want nil")
+	}
+}
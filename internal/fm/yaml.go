@@ -0,0 +1,462 @@
+package fm
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlDoc is a YAML header parsed into a node tree. Edits locate the affected
+// key through the tree and splice only the lines that belong to it, so
+// comments, blank lines, key order and quoting elsewhere are left untouched.
+type yamlDoc struct {
+	lines []string
+	root  *yaml.Node // top-level mapping, nil for an empty or comment-only header
+}
+
+func parseYAML(header string) (*yamlDoc, error) {
+	d := &yamlDoc{lines: splitLines(header)}
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(header), &doc); err != nil {
+		return nil, fmt.Errorf("parse yaml front matter: %w", err)
+	}
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 {
+		root := doc.Content[0]
+		if root.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("yaml front matter is not a mapping")
+		}
+		d.root = root
+	}
+	return d, nil
+}
+
+func (d *yamlDoc) String() string {
+	if len(d.lines) == 0 {
+		return ""
+	}
+	return strings.Join(d.lines, "\n") + "\n"
+}
+
+// lookup returns the value node at path, following aliases and merge keys.
+func (d *yamlDoc) lookup(path []string) (*yaml.Node, bool) {
+	if d.root == nil {
+		return nil, false
+	}
+	n := d.root
+	for _, key := range path {
+		n = resolveAlias(n)
+		if n.Kind != yaml.MappingNode {
+			return nil, false
+		}
+		v, ok := mappingGet(n, key)
+		if !ok {
+			return nil, false
+		}
+		n = v
+	}
+	return resolveAlias(n), true
+}
+
+func resolveAlias(n *yaml.Node) *yaml.Node {
+	for n != nil && n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	return n
+}
+
+// mappingGet looks up key in a mapping node, falling back to "<<" merges.
+func mappingGet(m *yaml.Node, key string) (*yaml.Node, bool) {
+	if i := mappingIndex(m, key); i >= 0 {
+		return m.Content[i+1], true
+	}
+	if i := mappingIndex(m, "<<"); i >= 0 {
+		merged := resolveAlias(m.Content[i+1])
+		var sources []*yaml.Node
+		if merged.Kind == yaml.SequenceNode {
+			sources = merged.Content
+		} else {
+			sources = []*yaml.Node{merged}
+		}
+		for _, s := range sources {
+			s = resolveAlias(s)
+			if s.Kind == yaml.MappingNode {
+				if v, ok := mappingGet(s, key); ok {
+					return v, true
+				}
+			}
+		}
+	}
+	return nil, false
+}
+
+// mappingIndex returns the index of key's key node in m.Content, or -1.
+func mappingIndex(m *yaml.Node, key string) int {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if k := m.Content[i]; k.Kind == yaml.ScalarNode && k.Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// set stores value at path, creating intermediate mappings as needed.
+// The existing scalar style and line comment are carried over to value.
+func (d *yamlDoc) set(path []string, value *yaml.Node) error {
+	if d.root == nil {
+		// Empty header: everything goes at the end, after any comments.
+		d.lines = append(trimTrailingBlank(d.lines), renderYAMLPair(path[0], nestYAML(path[1:], value), 0)...)
+		return d.reparse()
+	}
+
+	m := d.root
+	var outer [2]int // line range of the nearest enclosing block pair
+	outerKey := -1
+	var outerMap *yaml.Node
+	for depth, key := range path {
+		flow := m.Style&yaml.FlowStyle != 0
+		i := mappingIndex(m, key)
+		if i < 0 {
+			if flow {
+				// Flow mappings are edited in the tree and re-rendered from
+				// the nearest block-level ancestor.
+				m.Content = append(m.Content, scalarKey(key), nestYAML(path[depth+1:], value))
+				return d.rerender(outerMap, outerKey, outer)
+			}
+			_, end := d.pairRange(m, len(m.Content)-2, outer[1])
+			ins := renderYAMLPair(key, nestYAML(path[depth+1:], value), m.Content[0].Column-1)
+			d.lines = append(d.lines[:end], append(ins, d.lines[end:]...)...)
+			return d.reparse()
+		}
+		v := m.Content[i+1]
+		if !flow {
+			outerMap, outerKey = m, i
+			outer[0], outer[1] = d.pairRange(m, i, outer[1])
+		}
+		if depth == len(path)-1 {
+			inheritStyle(value, v)
+			m.Content[i+1] = value
+			return d.rerender(outerMap, outerKey, outer)
+		}
+		if v.Kind != yaml.MappingNode {
+			nested := nestYAML(path[depth+1:], value)
+			nested.LineComment = v.LineComment
+			m.Content[i+1] = nested
+			return d.rerender(outerMap, outerKey, outer)
+		}
+		m = v
+	}
+	return nil
+}
+
+// remove deletes the key at path. Missing keys are not an error.
+func (d *yamlDoc) remove(path []string) error {
+	if d.root == nil {
+		return nil
+	}
+	m := d.root
+	var outer [2]int
+	outerKey := -1
+	var outerMap *yaml.Node
+	for depth, key := range path {
+		m = resolveAlias(m)
+		if m.Kind != yaml.MappingNode {
+			return nil
+		}
+		i := mappingIndex(m, key)
+		if i < 0 {
+			return nil
+		}
+		flow := m.Style&yaml.FlowStyle != 0
+		if !flow {
+			start, end := d.pairRange(m, i, outer[1])
+			if depth == len(path)-1 {
+				if len(m.Content) == 2 && m != d.root {
+					// Removing the only key would leave a null value behind;
+					// render the parent as an empty flow mapping instead.
+					m.Content = nil
+					m.Style = yaml.FlowStyle
+					return d.rerender(outerMap, outerKey, outer)
+				}
+				d.lines = append(d.lines[:start], d.lines[end:]...)
+				return d.reparse()
+			}
+			outerMap, outerKey = m, i
+			outer[0], outer[1] = start, end
+		} else if depth == len(path)-1 {
+			m.Content = append(m.Content[:i], m.Content[i+2:]...)
+			return d.rerender(outerMap, outerKey, outer)
+		}
+		m = m.Content[i+1]
+	}
+	return nil
+}
+
+// rerender replaces the lines of the pair at index i of block mapping m with
+// a fresh rendering of its (possibly modified) node tree.
+func (d *yamlDoc) rerender(m *yaml.Node, i int, r [2]int) error {
+	if m == nil {
+		return fmt.Errorf("cannot edit yaml front matter: no block-level key to rewrite")
+	}
+	k, v := m.Content[i], m.Content[i+1]
+	col := k.Column - 1
+	// The head comment sits above r[0] and is kept as text.
+	hc := k.HeadComment
+	k.HeadComment = ""
+	rendered := renderYAMLNodePair(k, v, col)
+	k.HeadComment = hc
+	d.lines = append(d.lines[:r[0]], append(rendered, d.lines[r[1]:]...)...)
+	return d.reparse()
+}
+
+// pairRange returns the [start, end) line range of the pair whose key is at
+// index i of m. limit is the end of the enclosing pair (0 for the document).
+// Trailing blank lines and comments at or left of the key column are treated
+// as separators that belong to whatever follows.
+func (d *yamlDoc) pairRange(m *yaml.Node, i, limit int) (int, int) {
+	k := m.Content[i]
+	start := k.Line - 1
+	end := len(d.lines)
+	if limit > 0 {
+		end = limit
+	}
+	if i+2 < len(m.Content) {
+		end = m.Content[i+2].Line - 1
+	}
+	col := k.Column - 1
+	for end > start+1 {
+		l := d.lines[end-1]
+		t := strings.TrimSpace(l)
+		if t == "" || (strings.HasPrefix(t, "#") && indentOf(l) <= col) {
+			end--
+			continue
+		}
+		break
+	}
+	return start, end
+}
+
+func (d *yamlDoc) reparse() error {
+	nd, err := parseYAML(d.String())
+	if err != nil {
+		return err
+	}
+	*d = *nd
+	return nil
+}
+
+func indentOf(l string) int {
+	return len(l) - len(strings.TrimLeft(l, " \t"))
+}
+
+func splitLines(s string) []string {
+	s = strings.TrimRight(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+func trimTrailingBlank(lines []string) []string {
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// inheritStyle carries the quoting style and line comment of the node being
+// replaced over to its replacement, when the kinds are compatible.
+func inheritStyle(n, old *yaml.Node) {
+	old = resolveAlias(old)
+	if old == nil {
+		return
+	}
+	if n.LineComment == "" {
+		n.LineComment = old.LineComment
+	}
+	if n.Kind != old.Kind {
+		return
+	}
+	switch n.Kind {
+	case yaml.ScalarNode:
+		if n.Tag == "!!str" && old.Style&(yaml.LiteralStyle|yaml.FoldedStyle) == 0 {
+			n.Style = old.Style &^ yaml.TaggedStyle
+		}
+	case yaml.SequenceNode, yaml.MappingNode:
+		n.Style = old.Style & yaml.FlowStyle
+	}
+}
+
+func scalarKey(key string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}
+}
+
+// nestYAML wraps value in one mapping per remaining path element.
+func nestYAML(rest []string, value *yaml.Node) *yaml.Node {
+	for i := len(rest) - 1; i >= 0; i-- {
+		value = &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{scalarKey(rest[i]), value}}
+	}
+	return value
+}
+
+func renderYAMLPair(key string, value *yaml.Node, col int) []string {
+	return renderYAMLNodePair(scalarKey(key), value, col)
+}
+
+func renderYAMLNodePair(k, v *yaml.Node, col int) []string {
+	m := &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{k, v}}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	_ = enc.Encode(m)
+	_ = enc.Close()
+	lines := splitLines(buf.String())
+	pad := strings.Repeat(" ", col)
+	for i, l := range lines {
+		if l != "" {
+			lines[i] = pad + l
+		}
+	}
+	return lines
+}
+
+// yamlValue converts a Go value into a node. Strings are quoted; rawValue is
+// emitted plain and left to YAML's own type resolution.
+func yamlValue(v any) *yaml.Node {
+	switch x := v.(type) {
+	case *yaml.Node:
+		return x
+	case rawValue:
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: string(x)}
+	case string:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: x, Style: yaml.DoubleQuotedStyle}
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: fmt.Sprint(x)}
+	case int, int64, float64:
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: fmt.Sprint(x)}
+	case time.Time:
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: x.Format(time.RFC3339)}
+	case []string:
+		n := &yaml.Node{Kind: yaml.SequenceNode}
+		for _, s := range x {
+			n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: s})
+		}
+		return n
+	case []any:
+		n := &yaml.Node{Kind: yaml.SequenceNode}
+		for _, e := range x {
+			n.Content = append(n.Content, yamlValue(e))
+		}
+		return n
+	case map[string]any:
+		n := &yaml.Node{Kind: yaml.MappingNode}
+		for _, k := range sortedKeys(x) {
+			val := yamlValue(x[k])
+			if s, ok := x[k].(string); ok {
+				// Nested strings read better plain; the encoder still quotes
+				// them when YAML would otherwise change their type.
+				val = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: s}
+			}
+			n.Content = append(n.Content, scalarKey(k), val)
+		}
+		return n
+	}
+	return &yaml.Node{Kind: yaml.ScalarNode, Value: fmt.Sprint(v)}
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// yamlScalarString returns the string form of a scalar node, or "" for
+// anything that is not a scalar.
+func yamlScalarString(n *yaml.Node) string {
+	if n == nil || n.Kind != yaml.ScalarNode || n.Tag == "!!null" {
+		return ""
+	}
+	return n.Value
+}
+
+// yamlData decodes n into plain Go data as Node.Decode does into an any,
+// except that a timestamp written without an offset comes out as a
+// floatingTime, so that it can be read in the site's time zone.
+func yamlData(n *yaml.Node) (any, error) {
+	n = resolveAlias(n)
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return nil, nil
+		}
+		return yamlData(n.Content[0])
+	case yaml.SequenceNode:
+		out := make([]any, len(n.Content))
+		for i, e := range n.Content {
+			v, err := yamlData(e)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = v
+		}
+		return out, nil
+	case yaml.MappingNode:
+		out := map[string]any{}
+		// Merged maps come first, the earlier ones winning; the mapping's
+		// own keys override them
+		var merges []*yaml.Node
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if n.Content[i].ShortTag() != "!!merge" {
+				continue
+			}
+			if v := resolveAlias(n.Content[i+1]); v.Kind == yaml.SequenceNode {
+				merges = append(merges, v.Content...)
+			} else {
+				merges = append(merges, v)
+			}
+		}
+		for i := len(merges) - 1; i >= 0; i-- {
+			m, err := yamlData(merges[i])
+			if err != nil {
+				return nil, err
+			}
+			mm, ok := m.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("line %d: map merge requires a map or a list of maps", merges[i].Line)
+			}
+			for k, v := range mm {
+				out[k] = v
+			}
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k := n.Content[i]
+			if k.ShortTag() == "!!merge" {
+				continue
+			}
+			var key any
+			if err := k.Decode(&key); err != nil {
+				return nil, err
+			}
+			v, err := yamlData(n.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			out[fmt.Sprint(key)] = v
+		}
+		return out, nil
+	}
+	var x any
+	if err := n.Decode(&x); err != nil {
+		return nil, err
+	}
+	if t, ok := x.(time.Time); ok && !hasOffset(n.Value) {
+		return floatingTime(t), nil
+	}
+	return x, nil
+}
@@ -0,0 +1,130 @@
+package fm
+
+import "testing"
+
+func TestYAMLEdit(t *testing.T) {
+	runEditTests(t, []editTest{
+		{
+			"comments are kept",
+			"---\n# about the page\ntitle: A # short\n\n# labels\ntags: [a]\n---\nbody\n",
+			set("lastmod", rawValue("2025-01-01")),
+			"---\n# about the page\ntitle: A # short\n\n# labels\ntags: [a]\nlastmod: 2025-01-01\n---\nbody\n",
+		},
+		{
+			"replaced value keeps its comment and quoting",
+			"---\ntitle: 'A' # short\ndraft: true\n---\n",
+			set("title", "B"),
+			"---\ntitle: 'B' # short\ndraft: true\n---\n",
+		},
+		{
+			"key order and plain style are kept",
+			"---\nz: 1\ntitle: A\na: 2\n---\n",
+			set("title", "B"),
+			"---\nz: 1\ntitle: B\na: 2\n---\n",
+		},
+		{
+			"block list",
+			"---\nh:\n  - a\n# after\nx: 1\n---\n",
+			setList("h", "a", "b"),
+			"---\nh:\n  - a\n  - b\n# after\nx: 1\n---\n",
+		},
+		{
+			"flow list stays flow",
+			"---\nh: [a]\nx: 1\n---\n",
+			setList("h", "a", "b"),
+			"---\nh: [a, b]\nx: 1\n---\n",
+		},
+		{
+			"flow mapping stays flow",
+			"---\nparams: {a: 1}\n---\n",
+			set("params.b", "x"),
+			"---\nparams: {a: 1, b: \"x\"}\n---\n",
+		},
+		{
+			"nested key in a block mapping",
+			"---\nparams:\n  a: 1 # one\n\ntitle: A\n---\n",
+			set("params.b", "x"),
+			"---\nparams:\n  a: 1 # one\n  b: \"x\"\n\ntitle: A\n---\n",
+		},
+		{
+			"missing parents are created",
+			"---\ntitle: A\n---\n",
+			set("params.author.name", "J"),
+			"---\ntitle: A\nparams:\n  author:\n    name: \"J\"\n---\n",
+		},
+		{
+			"anchors and aliases are kept",
+			"---\ndefaults: &d\n  author: X\nparams:\n  <<: *d\n  x: 1\n---\n",
+			set("title", "A"),
+			"---\ndefaults: &d\n  author: X\nparams:\n  <<: *d\n  x: 1\ntitle: \"A\"\n---\n",
+		},
+		{
+			"remove a block",
+			"---\ntitle: A\nparams:\n  a: 1\n  b: 2\n# tail\ndraft: false\n---\n",
+			remove("params"),
+			"---\ntitle: A\n# tail\ndraft: false\n---\n",
+		},
+		{
+			"remove the only nested key",
+			"---\nparams:\n  a: 1\ntitle: A\n---\n",
+			remove("params.a"),
+			"---\nparams: {}\ntitle: A\n---\n",
+		},
+		{
+			"remove from a flow mapping",
+			"---\nparams: {a: 1, b: 2}\n---\n",
+			remove("params.a"),
+			"---\nparams: {b: 2}\n---\n",
+		},
+		{
+			"remove a missing key",
+			"---\ntitle: A\n---\n",
+			remove("params.a"),
+			"---\ntitle: A\n---\n",
+		},
+		{
+			"comment-only header",
+			"---\n# nothing yet\n---\n",
+			set("title", "A"),
+			"---\n# nothing yet\ntitle: \"A\"\n---\n",
+		},
+		{
+			"list of maps",
+			"---\ntitle: A\n---\n",
+			set("revisions", []any{map[string]any{"label": "v1", "current": true}}),
+			"---\ntitle: A\nrevisions:\n  - current: true\n    label: v1\n---\n",
+		},
+	})
+}
+
+func TestYAMLGet(t *testing.T) {
+	f, err := Parse("---\ndefaults: &d\n  author: X\nparams:\n  <<: *d\n  x: 1\ndate: 2024-06-15\n---\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := GetValue(f, "title"); got != "" {
+		t.Errorf("title = %q, want none", got)
+	}
+	if v, ok := Get(f, "params.author"); !ok || v.String() != "X" {
+		t.Errorf("params.author through a merge key = %q, %v", v.String(), ok)
+	}
+	if v, ok := Get(f, "params.x"); !ok || v.Kind != IntKind {
+		t.Errorf("params.x = %v, want an int", v.Kind)
+	}
+	if v, ok := Get(f, "date"); !ok || v.Kind != TimeKind {
+		t.Errorf("date = %v, want a time", v.Kind)
+	}
+}
+
+func TestYAMLInvalid(t *testing.T) {
+	f, err := Parse("---\ntags: [a, b\n---\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := InjectKV(f, "url", "/a/"); err == nil {
+		t.Error("InjectKV on invalid YAML: want an error")
+	}
+	if _, err := InjectBuildOptions(f); err == nil {
+		t.Error("InjectBuildOptions on invalid YAML: want an error")
+	}
+}
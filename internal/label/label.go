@@ -0,0 +1,262 @@
+// Package label names versions and orders their labels.
+package label
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/ifeitao/hugo-revise/internal/config"
+)
+
+// Context is what a label is made from.
+type Context struct {
+	Date    time.Time      // page date for First, revision time for Next
+	Counter int            // 1-based number of the version being labelled
+	Page    map[string]any // front matter of the page
+	Bump    string         // semver part to bump: major, minor or patch
+}
+
+// Strategy names versions. Every place that creates, checks or orders
+// labels goes through the same Strategy.
+type Strategy interface {
+	// First labels the version archived when a page is revised for the
+	// first time.
+	First(ctx Context) (string, error)
+	// Next labels the new current version; existing holds the labels of
+	// all earlier versions, oldest first.
+	Next(ctx Context, existing []string) (string, error)
+	// Less orders labels from oldest to newest.
+	Less(a, b string) bool
+}
+
+// New returns the strategy configured in [versioning].
+func New(cfg config.Versioning) (Strategy, error) {
+	switch cfg.Label {
+	case "", "date":
+		return dateStrategy{layout: cfg.DateFormat}, nil
+	case "datetime":
+		return dateStrategy{layout: cfg.DateTimeFormat}, nil
+	case "sequential":
+		return sequentialStrategy{}, nil
+	case "semver":
+		return semverStrategy{}, nil
+	case "template":
+		if cfg.Template == "" {
+			return nil, fmt.Errorf("versioning.label = \"template\" needs versioning.template")
+		}
+		t, err := template.New("label").Option("missingkey=zero").Parse(cfg.Template)
+		if err != nil {
+			return nil, fmt.Errorf("parse versioning.template: %w", err)
+		}
+		return templateStrategy{t: t}, nil
+	}
+	return nil, fmt.Errorf("unknown label strategy %q (want date, datetime, sequential, semver or template)", cfg.Label)
+}
+
+// Check reports whether l can name a version: it becomes a directory or
+// file name and part of a URL, so it must be a single path segment.
+func Check(l string) error {
+	if l == "" || l == "." || l == ".." || strings.ContainsAny(l, "/\\ \t\n") {
+		return fmt.Errorf("unusable label %q: it must be a non-empty path segment without spaces", l)
+	}
+	return nil
+}
+
+// Sort orders labels oldest first.
+func Sort(s Strategy, labels []string) {
+	sort.SliceStable(labels, func(i, j int) bool { return s.Less(labels[i], labels[j]) })
+}
+
+// Latest returns the newest of labels.
+func Latest(s Strategy, labels []string) string {
+	latest := ""
+	for i, l := range labels {
+		if i == 0 || s.Less(latest, l) {
+			latest = l
+		}
+	}
+	return latest
+}
+
+// dateStrategy labels versions with their date in a Go time layout.
+type dateStrategy struct {
+	layout string
+}
+
+func (s dateStrategy) First(ctx Context) (string, error) {
+	return ctx.Date.Format(s.layout), nil
+}
+
+func (s dateStrategy) Next(ctx Context, existing []string) (string, error) {
+	return ctx.Date.Format(s.layout), nil
+}
+
+func (s dateStrategy) Less(a, b string) bool {
+	ta, errA := time.Parse(s.layout, a)
+	tb, errB := time.Parse(s.layout, b)
+	if errA == nil && errB == nil && !ta.Equal(tb) {
+		return ta.Before(tb)
+	}
+	return naturalLess(a, b)
+}
+
+// sequentialStrategy labels versions v1, v2, v3, ...
+type sequentialStrategy struct{}
+
+var sequentialRe = regexp.MustCompile(`^v(\d+)$`)
+
+func (sequentialStrategy) First(ctx Context) (string, error) {
+	return "v1", nil
+}
+
+// Next numbers past both the highest vN and the number of earlier
+// versions, so labels from another strategy still count.
+func (sequentialStrategy) Next(ctx Context, existing []string) (string, error) {
+	n := len(existing)
+	for _, l := range existing {
+		if m := sequentialRe.FindStringSubmatch(l); m != nil {
+			if i, _ := strconv.Atoi(m[1]); i > n {
+				n = i
+			}
+		}
+	}
+	return fmt.Sprintf("v%d", n+1), nil
+}
+
+func (sequentialStrategy) Less(a, b string) bool {
+	return naturalLess(a, b)
+}
+
+// semverStrategy labels versions v1.0.0, v1.1.0, v2.0.0, ...
+type semverStrategy struct{}
+
+var semverRe = regexp.MustCompile(`^v?(\d+)\.(\d+)\.(\d+)$`)
+
+func parseSemver(l string) ([3]int, bool) {
+	m := semverRe.FindStringSubmatch(l)
+	if m == nil {
+		return [3]int{}, false
+	}
+	var v [3]int
+	for i := range v {
+		v[i], _ = strconv.Atoi(m[i+1])
+	}
+	return v, true
+}
+
+func (semverStrategy) First(ctx Context) (string, error) {
+	return "v1.0.0", nil
+}
+
+// Next bumps the highest semver label; without one the history starts at
+// v1.0.0.
+func (s semverStrategy) Next(ctx Context, existing []string) (string, error) {
+	var latest [3]int
+	found := false
+	for _, l := range existing {
+		if v, ok := parseSemver(l); ok && (!found || semverLess(latest, v)) {
+			latest, found = v, true
+		}
+	}
+	if !found {
+		return "v1.0.0", nil
+	}
+	switch ctx.Bump {
+	case "major":
+		latest = [3]int{latest[0] + 1, 0, 0}
+	case "", "minor":
+		latest = [3]int{latest[0], latest[1] + 1, 0}
+	case "patch":
+		latest[2]++
+	default:
+		return "", fmt.Errorf("unknown --bump %q (want major, minor or patch)", ctx.Bump)
+	}
+	return fmt.Sprintf("v%d.%d.%d", latest[0], latest[1], latest[2]), nil
+}
+
+func (semverStrategy) Less(a, b string) bool {
+	va, okA := parseSemver(a)
+	vb, okB := parseSemver(b)
+	if okA && okB {
+		return semverLess(va, vb)
+	}
+	return naturalLess(a, b)
+}
+
+func semverLess(a, b [3]int) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return false
+}
+
+// templateStrategy renders a text/template over Context, e.g.
+// {{ .Date.Format "2006" }}-r{{ .Counter }}.
+type templateStrategy struct {
+	t *template.Template
+}
+
+func (s templateStrategy) render(ctx Context) (string, error) {
+	var buf bytes.Buffer
+	if err := s.t.Execute(&buf, ctx); err != nil {
+		return "", fmt.Errorf("render versioning.template: %w", err)
+	}
+	l := strings.TrimSpace(buf.String())
+	if err := Check(l); err != nil {
+		return "", fmt.Errorf("versioning.template: %w", err)
+	}
+	return l, nil
+}
+
+func (s templateStrategy) First(ctx Context) (string, error) {
+	return s.render(ctx)
+}
+
+func (s templateStrategy) Next(ctx Context, existing []string) (string, error) {
+	return s.render(ctx)
+}
+
+func (templateStrategy) Less(a, b string) bool {
+	return naturalLess(a, b)
+}
+
+// naturalLess compares strings with runs of digits compared as numbers,
+// so v2 sorts before v10.
+func naturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		da, db := digitPrefix(a), digitPrefix(b)
+		if da != "" && db != "" {
+			na := strings.TrimLeft(da, "0")
+			nb := strings.TrimLeft(db, "0")
+			if len(na) != len(nb) {
+				return len(na) < len(nb)
+			}
+			if na != nb {
+				return na < nb
+			}
+			a, b = a[len(da):], b[len(db):]
+			continue
+		}
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) < len(b)
+}
+
+func digitPrefix(s string) string {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return s[:i]
+}
@@ -0,0 +1,119 @@
+package label
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ifeitao/hugo-revise/internal/config"
+)
+
+func strategy(t *testing.T, cfg config.Versioning) Strategy {
+	t.Helper()
+	if cfg.DateFormat == "" {
+		cfg.DateFormat = "2006-01-02"
+	}
+	if cfg.DateTimeFormat == "" {
+		cfg.DateTimeFormat = "2006-01-02-1504"
+	}
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return s
+}
+
+func TestLess(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.Versioning
+		a, b string
+		want bool
+	}{
+		{"date", config.Versioning{Label: "date"}, "2024-06-15", "2025-01-01", true},
+		{"date reversed", config.Versioning{Label: "date"}, "2025-01-01", "2024-06-15", false},
+		{"date day layout", config.Versioning{Label: "date", DateFormat: "02-01-2006"}, "15-06-2024", "01-01-2025", true},
+		{"date and suffixed date", config.Versioning{Label: "date"}, "2024-06-15", "2024-06-15-2", true},
+		{"datetime", config.Versioning{Label: "datetime"}, "2024-06-15-0930", "2024-06-15-1400", true},
+		{"sequential v9 v10", config.Versioning{Label: "sequential"}, "v9", "v10", true},
+		{"sequential v10 v9", config.Versioning{Label: "sequential"}, "v10", "v9", false},
+		{"semver minor", config.Versioning{Label: "semver"}, "v1.9.0", "v1.10.0", true},
+		{"semver major", config.Versioning{Label: "semver"}, "v2.0.0", "v10.0.0", true},
+		{"semver without v", config.Versioning{Label: "semver"}, "1.2.3", "v1.2.4", true},
+		{"semver equal", config.Versioning{Label: "semver"}, "v1.2.3", "v1.2.3", false},
+		{"template", config.Versioning{Label: "template", Template: "r{{ .Counter }}"}, "r9", "r10", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := strategy(t, tt.cfg).Less(tt.a, tt.b); got != tt.want {
+				t.Errorf("Less(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	date := time.Date(2024, 6, 15, 9, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		cfg      config.Versioning
+		ctx      Context
+		existing []string
+		want     string
+	}{
+		{"date", config.Versioning{Label: "date"}, Context{Date: date}, []string{"2024-01-01"}, "2024-06-15"},
+		{"datetime", config.Versioning{Label: "datetime"}, Context{Date: date}, nil, "2024-06-15-0930"},
+		{"sequential", config.Versioning{Label: "sequential"}, Context{}, []string{"v1", "v2"}, "v3"},
+		{"sequential past v9", config.Versioning{Label: "sequential"}, Context{}, []string{"v9", "v10"}, "v11"},
+		{"sequential after dates", config.Versioning{Label: "sequential"}, Context{}, []string{"2024-01-01", "2024-03-01"}, "v3"},
+		{"semver default bump", config.Versioning{Label: "semver"}, Context{}, []string{"v1.0.0", "v1.1.0"}, "v1.2.0"},
+		{"semver major", config.Versioning{Label: "semver"}, Context{Bump: "major"}, []string{"v1.2.3"}, "v2.0.0"},
+		{"semver minor", config.Versioning{Label: "semver"}, Context{Bump: "minor"}, []string{"v1.2.3"}, "v1.3.0"},
+		{"semver patch", config.Versioning{Label: "semver"}, Context{Bump: "patch"}, []string{"v1.2.3"}, "v1.2.4"},
+		{"semver bumps the highest", config.Versioning{Label: "semver"}, Context{Bump: "patch"}, []string{"v1.10.0", "v1.9.0"}, "v1.10.1"},
+		{"semver without one", config.Versioning{Label: "semver"}, Context{}, []string{"2024-01-01"}, "v1.0.0"},
+		{"template", config.Versioning{Label: "template", Template: `{{ .Date.Format "2006" }}-r{{ .Counter }}`}, Context{Date: date, Counter: 3}, nil, "2024-r3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := strategy(t, tt.cfg).Next(tt.ctx, tt.existing)
+			if err != nil {
+				t.Fatalf("Next: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Next = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.Versioning
+		ctx  Context
+	}{
+		{"unknown bump", config.Versioning{Label: "semver"}, Context{Bump: "huge"}},
+		{"template with a slash", config.Versioning{Label: "template", Template: "a/b"}, Context{}},
+		{"empty template output", config.Versioning{Label: "template", Template: "{{ .Page.missing }}"}, Context{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := strategy(t, tt.cfg).Next(tt.ctx, []string{"v1.0.0"}); err == nil {
+				t.Errorf("Next = %q, want an error", got)
+			}
+		})
+	}
+}
+
+func TestLatest(t *testing.T) {
+	s := strategy(t, config.Versioning{Label: "sequential"})
+	labels := []string{"v10", "v2", "v9", "v1"}
+	if got := Latest(s, labels); got != "v10" {
+		t.Errorf("Latest = %q, want v10", got)
+	}
+	Sort(s, labels)
+	if got, want := labels, []string{"v1", "v2", "v9", "v10"}; !slices.Equal(got, want) {
+		t.Errorf("Sort = %v, want %v", got, want)
+	}
+}
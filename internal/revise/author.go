@@ -0,0 +1,53 @@
+package revise
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// resolveAuthor returns who makes a revision: the --author flag, else
+// HUGO_REVISE_AUTHOR, else git's user.name and user.email as seen from dir.
+// name is the display name written to front matter and manifests, which
+// Hugo publishes; contact adds the email address, "Name <email>" as git
+// writes authors, for the undo log only. Both are "" when none of them is
+// set.
+func resolveAuthor(flag, dir string) (name, contact string) {
+	if a := strings.TrimSpace(flag); a != "" {
+		return displayName(a), a
+	}
+	if a := strings.TrimSpace(os.Getenv("HUGO_REVISE_AUTHOR")); a != "" {
+		return displayName(a), a
+	}
+	name = gitConfig(dir, "user.name")
+	email := gitConfig(dir, "user.email")
+	switch {
+	case name != "" && email != "":
+		return name, name + " <" + email + ">"
+	case email != "":
+		return "", "<" + email + ">"
+	}
+	return name, name
+}
+
+// displayName returns the name in an author written "Name <email>", and ""
+// for an email address alone.
+func displayName(author string) string {
+	if i := strings.Index(author, "<"); i >= 0 && strings.HasSuffix(author, ">") {
+		return strings.TrimSpace(author[:i])
+	}
+	if strings.Contains(author, "@") && !strings.ContainsAny(author, " \t") {
+		return ""
+	}
+	return author
+}
+
+func gitConfig(dir, key string) string {
+	cmd := exec.Command("git", "config", "--get", key)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
@@ -0,0 +1,186 @@
+package revise
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/ifeitao/hugo-revise/internal/config"
+	"github.com/ifeitao/hugo-revise/internal/fm"
+	"github.com/ifeitao/hugo-revise/internal/label"
+)
+
+// What to do when the label of the new version is already taken, e.g. when
+// a page is revised twice on one day with date labels.
+const (
+	collisionError     = "error"     // refuse the revision
+	collisionSuffix    = "suffix"    // label the new version 2025-12-01-2, -3, ...
+	collisionTime      = "time"      // label the new version with the time as well, versioning.datetime_format
+	collisionAmend     = "amend"     // archive nothing; the new content replaces the current version
+	collisionOverwrite = "overwrite" // archive again, replacing an archive that has the label
+)
+
+// overwrittenDir holds the archives replaced by the last revision, so that
+// undo can put them back.
+var overwrittenDir = filepath.Join(config.LogDirectory, "overwritten")
+
+// collisionMode returns the collision handling asked for on the command line
+// or else in [versioning].
+func collisionMode(cfg config.Config, opts Options) (string, error) {
+	mode := opts.OnCollision
+	if mode == "" {
+		mode = cfg.Versioning.OnCollision
+	}
+	switch mode {
+	case "":
+		return collisionError, nil
+	case collisionError, collisionSuffix, collisionTime, collisionAmend, collisionOverwrite:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown on_collision %q (want error, suffix, time, amend or overwrite)", mode)
+}
+
+// collides reports whether the new label of r is already used: by the
+// version being archived, or by an archive.
+func collides(r *revision) bool {
+	return r.latest == r.version || isArchived(r, r.latest)
+}
+
+func isArchived(r *revision, l string) bool {
+	for _, a := range r.page.Archives() {
+		if a.Label == l {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveCollisions applies mode to the revisions whose new label is taken
+// and reports whether there were any. Translations revised together keep
+// one label, so a new label must be free for all of them.
+func resolveCollisions(cfg config.Config, strategy label.Strategy, revisions []*revision, mode string, now time.Time) (bool, error) {
+	var colliding []*revision
+	for _, r := range revisions {
+		if collides(r) {
+			colliding = append(colliding, r)
+		}
+	}
+	if len(colliding) == 0 {
+		return false, nil
+	}
+	taken := func(l string) bool {
+		for _, r := range revisions {
+			if l == r.version || isArchived(r, l) {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch mode {
+	case collisionSuffix, collisionTime:
+		latest := colliding[0].latest
+		if mode == collisionSuffix {
+			for n := 2; taken(latest); n++ {
+				latest = colliding[0].latest + "-" + strconv.Itoa(n)
+			}
+		} else {
+			latest = now.Format(cfg.Versioning.DateTimeFormat)
+			if taken(latest) {
+				return true, fmt.Errorf("a revision labelled %s already exists for %s", latest, colliding[0].page.Source)
+			}
+		}
+		for _, r := range revisions {
+			r.latest = latest
+			r.versions = withLabels(strategy, r.page, r.version, latest)
+		}
+	case collisionAmend:
+		for _, r := range colliding {
+			amendCurrent(strategy, r)
+		}
+	case collisionOverwrite:
+		// A label taken by the current version itself has no archive to
+		// replace: archiving it would give two versions one label, so the
+		// current version is amended instead
+		for _, r := range colliding {
+			if r.latest == r.version {
+				amendCurrent(strategy, r)
+			} else {
+				r.overwrite = true
+			}
+		}
+	default:
+		r := colliding[0]
+		switch cfg.Versioning.Label {
+		case "", "date":
+			return true, fmt.Errorf("a revision for %s already exists. hugo-revise is designed for major revisions, not daily updates. Please use git for granular version control, wait until a different day to create another revision, or set versioning.on_collision (--on-collision)", r.latest)
+		}
+		return true, fmt.Errorf("a revision labelled %s already exists for %s", r.latest, r.page.Source)
+	}
+	return true, nil
+}
+
+// amendCurrent makes r amend the current version: it keeps its label and
+// the history is unchanged.
+func amendCurrent(strategy label.Strategy, r *revision) {
+	r.amend = true
+	r.latest = r.version
+	r.versions = withLabels(strategy, r.page, r.version)
+}
+
+// backupArchives saves the archives that overwriting revisions are about to
+// replace and returns the changes that restore them.
+func backupArchives(revisions []*revision) ([]change, error) {
+	var changes []change
+	seen := map[string]bool{}
+	for _, r := range revisions {
+		target := r.page.ArchivePath(r.version)
+		if !r.overwrite || seen[target] || !exists(target) {
+			continue
+		}
+		seen[target] = true
+		backup := filepath.Join(overwrittenDir, strconv.Itoa(len(changes)), filepath.Base(target))
+		if err := os.MkdirAll(filepath.Dir(backup), 0o755); err != nil {
+			return nil, err
+		}
+		if err := copyPath(target, backup); err != nil {
+			return nil, fmt.Errorf("back up %s: %w", target, err)
+		}
+		changes = append(changes, change{Source: backup, Target: target, Action: "restore"})
+	}
+	return changes, nil
+}
+
+// copyPath copies a file, or a directory with everything in it.
+func copyPath(src, dst string) error {
+	fi, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		if err := os.MkdirAll(dst, 0o755); err != nil {
+			return err
+		}
+		return copyDirContents(src, dst, nil, resourceCopier{})
+	}
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, 0o644)
+}
+
+// amend writes a page whose current version absorbs the new content, with
+// its dates refreshed, without archiving anything. A note or author given
+// replaces the version's, and reaches its archives.
+func amend(r *revision) ([]change, error) {
+	if err := propagate(r); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(r.page.Source, []byte(fm.Stringify(r.updated)), 0o644); err != nil {
+		return nil, err
+	}
+	return []change{{Source: r.page.Source, Target: r.page.Source, Action: "write"}}, nil
+}
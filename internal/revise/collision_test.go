@@ -0,0 +1,118 @@
+package revise
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ifeitao/hugo-revise/internal/config"
+	"github.com/ifeitao/hugo-revise/internal/content"
+	"github.com/ifeitao/hugo-revise/internal/label"
+)
+
+// testPage writes the content file name under a temporary directory, with
+// archives of the given labels in its revisions directory, and returns the
+// page.
+func testPage(t *testing.T, dir, name string, archived ...string) content.Page {
+	t.Helper()
+	source := filepath.Join(dir, name)
+	page := content.FromSource(source, []string{"en", "zh"})
+	writeFile(t, source, "---\ntitle: A\n---\nbody\n")
+	for _, l := range archived {
+		writeFile(t, page.ArchiveFile(l), "---\ntitle: A\n---\nold body\n")
+	}
+	return page
+}
+
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testConfig() config.Config {
+	return config.Config{Versioning: config.Versioning{
+		Label:          "date",
+		DateFormat:     "2006-01-02",
+		DateTimeFormat: "2006-01-02-1504",
+	}}
+}
+
+func TestResolveCollisions(t *testing.T) {
+	now := time.Date(2024, 6, 15, 9, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		mode      string
+		archived  []string
+		version   string // label of the version being archived
+		latest    string // label asked for the new version
+		collided  bool
+		wantErr   bool
+		want      string // label of the new version once resolved
+		amend     bool
+		overwrite bool
+	}{
+		{name: "no collision", mode: collisionError, archived: []string{"2024-01-01"}, version: "2024-03-01", latest: "2024-06-15", want: "2024-06-15"},
+		{name: "error", mode: collisionError, version: "2024-06-15", latest: "2024-06-15", collided: true, wantErr: true},
+		{name: "suffix", mode: collisionSuffix, version: "2024-06-15", latest: "2024-06-15", collided: true, want: "2024-06-15-2"},
+		{name: "suffix past taken suffixes", mode: collisionSuffix, archived: []string{"2024-06-15", "2024-06-15-2"}, version: "2024-06-15-3", latest: "2024-06-15", collided: true, want: "2024-06-15-4"},
+		{name: "time", mode: collisionTime, version: "2024-06-15", latest: "2024-06-15", collided: true, want: "2024-06-15-0930"},
+		{name: "time taken", mode: collisionTime, archived: []string{"2024-06-15-0930"}, version: "2024-06-15", latest: "2024-06-15", collided: true, wantErr: true},
+		{name: "amend", mode: collisionAmend, archived: []string{"2024-01-01"}, version: "2024-06-15", latest: "2024-06-15", collided: true, want: "2024-06-15", amend: true},
+		{name: "amend an archived label", mode: collisionAmend, archived: []string{"2024-06-15"}, version: "2024-06-20", latest: "2024-06-15", collided: true, want: "2024-06-20", amend: true},
+		{name: "overwrite", mode: collisionOverwrite, archived: []string{"2024-06-15"}, version: "2024-06-20", latest: "2024-06-15", collided: true, want: "2024-06-15", overwrite: true},
+		{name: "overwrite the current label amends", mode: collisionOverwrite, version: "2024-06-15", latest: "2024-06-15", collided: true, want: "2024-06-15", amend: true},
+	}
+	cfg := testConfig()
+	strategy, err := label.New(cfg.Versioning)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &revision{page: testPage(t, t.TempDir(), "p.md", tt.archived...), version: tt.version, latest: tt.latest}
+			collided, err := resolveCollisions(cfg, strategy, []*revision{r}, tt.mode, now)
+			if collided != tt.collided {
+				t.Errorf("collided = %v, want %v", collided, tt.collided)
+			}
+			if tt.wantErr {
+				if err == nil {
+					t.Error("want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveCollisions: %v", err)
+			}
+			if r.latest != tt.want || r.amend != tt.amend || r.overwrite != tt.overwrite {
+				t.Errorf("latest, amend, overwrite = %s, %v, %v, want %s, %v, %v", r.latest, r.amend, r.overwrite, tt.want, tt.amend, tt.overwrite)
+			}
+		})
+	}
+}
+
+// Translations revised together get one label, free for all of them.
+func TestResolveCollisionsTranslations(t *testing.T) {
+	cfg := testConfig()
+	strategy, _ := label.New(cfg.Versioning)
+	dir := t.TempDir()
+	en := &revision{page: testPage(t, dir, "p.md"), version: "2024-06-15", latest: "2024-06-15"}
+	zh := &revision{page: testPage(t, dir, "p.zh.md", "2024-06-15-2"), version: "2024-06-15", latest: "2024-06-15"}
+	if _, err := resolveCollisions(cfg, strategy, []*revision{en, zh}, collisionSuffix, time.Now()); err != nil {
+		t.Fatalf("resolveCollisions: %v", err)
+	}
+	for _, r := range []*revision{en, zh} {
+		if r.latest != "2024-06-15-3" {
+			t.Errorf("%s: latest = %s, want 2024-06-15-3", r.page.Source, r.latest)
+		}
+		if !slices.Contains(r.versions, "2024-06-15-3") {
+			t.Errorf("%s: versions = %v, want 2024-06-15-3 among them", r.page.Source, r.versions)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package revise
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ifeitao/hugo-revise/internal/fm"
+)
+
+// dateLayout is how date and lastmod are written: RFC 3339 with a numeric
+// offset, never Z, so the zone a revision was made in is kept.
+const dateLayout = "2006-01-02T15:04:05-07:00"
+
+// resolveDate returns the first date provided by handlers, which follow
+// Hugo's [frontmatter] rules: ":filename" reads a YYYY-MM-DD prefix of the
+// file name (or the bundle directory name), ":fileModTime" the file's
+// modification time, ":git" the author date of the file's last commit, and
+// anything else names a front matter field, matched case-insensitively.
+// Dates without an offset are read in loc, and all are returned in it.
+func resolveDate(handlers []string, sourceFile string, frontMatter fm.FrontMatter, loc *time.Location) (time.Time, bool) {
+	for _, h := range handlers {
+		switch strings.ToLower(h) {
+		case ":filename":
+			if t, ok := dateFromFilename(sourceFile, loc); ok {
+				return t, true
+			}
+		case ":filemodtime":
+			if fi, err := os.Stat(sourceFile); err == nil {
+				return fi.ModTime().In(loc), true
+			}
+		case ":git":
+			if t, ok := dateFromGit(sourceFile); ok {
+				return t.In(loc), true
+			}
+		default:
+			if v, ok := fm.GetFold(frontMatter, h); ok {
+				if t, ok := v.TimeIn(loc); ok && !t.IsZero() {
+					return t.In(loc), true
+				}
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// dateFromFilename parses a leading date such as 2024-06-15-my-post.md.
+// Bundles take the date from their directory name.
+func dateFromFilename(sourceFile string, loc *time.Location) (time.Time, bool) {
+	name := filepath.Base(sourceFile)
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	if name == "index" || name == "_index" {
+		name = filepath.Base(filepath.Dir(sourceFile))
+	}
+	if len(name) < 10 {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation("2006-01-02", name[:10], loc)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// dateFromGit returns the author date of the last commit touching the file.
+func dateFromGit(sourceFile string) (time.Time, bool) {
+	cmd := exec.Command("git", "log", "-1", "--format=%aI", "--", filepath.Base(sourceFile))
+	cmd.Dir = filepath.Dir(sourceFile)
+	out, err := cmd.Output()
+	if err != nil {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, strings.TrimSpace(string(out)))
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
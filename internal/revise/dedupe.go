@@ -0,0 +1,253 @@
+package revise
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/ifeitao/hugo-revise/internal/config"
+	"github.com/ifeitao/hugo-revise/internal/label"
+	"github.com/ifeitao/hugo-revise/internal/site"
+)
+
+// How the resources of a bundle are copied into its archive.
+const (
+	dedupeOff      = "off"      // copy every file
+	dedupeHardlink = "hardlink" // link files unchanged since the latest archive to its copy
+	dedupeStore    = "store"    // keep each file once in objectsDir and link archives to it
+)
+
+// objectsDir holds the resources of archives by content hash when
+// storage.dedupe is store.
+var objectsDir = filepath.Join(config.LogDirectory, "objects")
+
+// dedupeMode returns storage.dedupe, checked.
+func dedupeMode(cfg config.Config) (string, error) {
+	switch mode := cfg.Storage.Dedupe; mode {
+	case "":
+		return dedupeOff, nil
+	case dedupeOff, dedupeHardlink, dedupeStore:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown storage.dedupe %q (want off, hardlink or store)", mode)
+	}
+}
+
+// resourceCopier copies the resources of a bundle into an archive. Files
+// are shared with earlier archives through hard links as mode says; where a
+// link cannot be made, across file systems for one, the file is copied.
+type resourceCopier struct {
+	mode     string
+	previous string // directory of the latest archive before, "" when there is none
+}
+
+// copierFor returns the copier of the resources of the version r archives.
+func copierFor(mode string, strategy label.Strategy, r *revision) resourceCopier {
+	c := resourceCopier{mode: mode}
+	if latest, ok := latestArchive(strategy, r); ok {
+		c.previous = filepath.Dir(latest.File)
+	}
+	return c
+}
+
+// copyFile copies src to dst, rel being its path inside the bundle.
+func (c resourceCopier) copyFile(src, dst, rel string) error {
+	// A linked file is shared with other archives: replace it, never write
+	// through it
+	if err := os.Remove(dst); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	switch c.mode {
+	case dedupeHardlink:
+		if c.previous != "" {
+			prev := filepath.Join(c.previous, rel)
+			if sameContent(src, prev) && os.Link(prev, dst) == nil {
+				return nil
+			}
+		}
+	case dedupeStore:
+		object, err := storeObject(src)
+		if err != nil {
+			return err
+		}
+		if os.Link(object, dst) == nil {
+			return nil
+		}
+	}
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, 0o644)
+}
+
+// sameContent reports whether the files a and b hold the same bytes.
+func sameContent(a, b string) bool {
+	ai, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bi, err := os.Stat(b)
+	if err != nil || !bi.Mode().IsRegular() || ai.Size() != bi.Size() {
+		return false
+	}
+	if os.SameFile(ai, bi) {
+		return true
+	}
+	ab, err := os.ReadFile(a)
+	if err != nil {
+		return false
+	}
+	bb, err := os.ReadFile(b)
+	return err == nil && bytes.Equal(ab, bb)
+}
+
+// storeObject adds the file at src to objectsDir, unless a file with its
+// content is there already, and returns the object's path.
+func storeObject(src string) (string, error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	object := filepath.Join(objectsDir, sum[:2], sum)
+	if exists(object) {
+		return object, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(object), 0o755); err != nil {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	// Written aside and renamed, so that an object is never left half
+	// written
+	tmp, err := os.CreateTemp(filepath.Dir(object), sum+".*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(tmp, f); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	// Archives linked to the object share its mode: keep the source's, not
+	// the temporary file's 0600
+	fi, err := f.Stat()
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := os.Chmod(tmp.Name(), fi.Mode().Perm()); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), object); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return object, nil
+}
+
+// Garbage is what CollectGarbage found in objectsDir.
+type Garbage struct {
+	Objects []string // objects no file in the content tree links to
+	Bytes   int64    // their total size
+}
+
+// CollectGarbage removes the objects of the content-addressed store that no
+// file in the content tree of the site in the working directory links to
+// any more, as after undoing revisions or deleting archives. With dryRun
+// set it only reports them.
+func CollectGarbage(dryRun bool) (Garbage, error) {
+	siteCfg, err := site.Discover(".")
+	if err != nil {
+		return Garbage{}, err
+	}
+
+	// Objects by size, so that only files of a matching size are compared
+	objects := map[int64][]fs.FileInfo{}
+	paths := map[fs.FileInfo]string{}
+	err = filepath.WalkDir(objectsDir, func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && path == objectsDir {
+			return fs.SkipAll
+		}
+		if err != nil || d.IsDir() {
+			return err
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		objects[fi.Size()] = append(objects[fi.Size()], fi)
+		paths[fi] = path
+		return nil
+	})
+	if err != nil {
+		return Garbage{}, err
+	}
+
+	linked := map[fs.FileInfo]bool{}
+	for _, root := range siteCfg.ContentRoots {
+		err := filepath.WalkDir(root.Dir, func(path string, d fs.DirEntry, err error) error {
+			if errors.Is(err, fs.ErrNotExist) && path == root.Dir {
+				return fs.SkipAll
+			}
+			if err != nil || d.IsDir() {
+				return err
+			}
+			fi, err := os.Stat(path)
+			if err != nil {
+				return err
+			}
+			for _, o := range objects[fi.Size()] {
+				if os.SameFile(o, fi) {
+					linked[o] = true
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return Garbage{}, err
+		}
+	}
+
+	var g Garbage
+	for _, list := range objects {
+		for _, o := range list {
+			if linked[o] {
+				continue
+			}
+			g.Objects = append(g.Objects, paths[o])
+			g.Bytes += o.Size()
+		}
+	}
+	sort.Strings(g.Objects)
+	if dryRun {
+		return g, nil
+	}
+	for _, path := range g.Objects {
+		if err := os.Remove(path); err != nil {
+			return g, err
+		}
+		// The fan-out directory goes once it is empty
+		os.Remove(filepath.Dir(path))
+	}
+	return g, nil
+}
@@ -0,0 +1,138 @@
+package revise
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func sameFile(t *testing.T, a, b string) bool {
+	t.Helper()
+	ai, err := os.Stat(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bi, err := os.Stat(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return os.SameFile(ai, bi)
+}
+
+// Unchanged resources are linked to the latest archive's copy; changed
+// ones are copied.
+func TestCopyFileHardlink(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "page/same.png"), "same")
+	writeFile(t, filepath.Join(dir, "page/changed.png"), "new")
+	writeFile(t, filepath.Join(dir, "v1/same.png"), "same")
+	writeFile(t, filepath.Join(dir, "v1/changed.png"), "old")
+	if err := os.MkdirAll(filepath.Join(dir, "v2"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	c := resourceCopier{mode: dedupeHardlink, previous: filepath.Join(dir, "v1")}
+	for _, name := range []string{"same.png", "changed.png"} {
+		if err := c.copyFile(filepath.Join(dir, "page", name), filepath.Join(dir, "v2", name), name); err != nil {
+			t.Fatalf("copyFile %s: %v", name, err)
+		}
+	}
+	if !sameFile(t, filepath.Join(dir, "v1/same.png"), filepath.Join(dir, "v2/same.png")) {
+		t.Error("same.png was not linked to the previous archive's")
+	}
+	if sameFile(t, filepath.Join(dir, "v1/changed.png"), filepath.Join(dir, "v2/changed.png")) {
+		t.Error("changed.png was linked to the previous archive's")
+	}
+	if b, _ := os.ReadFile(filepath.Join(dir, "v2/changed.png")); string(b) != "new" {
+		t.Errorf("changed.png = %q, want new", b)
+	}
+}
+
+// Archives share one stored object per content, which keeps the mode of
+// the file it was made from, and writing an archive again never changes
+// the object.
+func TestCopyFileStore(t *testing.T) {
+	testSite(t, map[string]string{
+		"page/image.png": "image",
+		"page/other.png": "other",
+	})
+	if err := os.Chmod("page/image.png", 0o640); err != nil {
+		t.Fatal(err)
+	}
+	c := resourceCopier{mode: dedupeStore}
+	for _, dst := range []string{"v1/image.png", "v2/image.png"} {
+		writeFile(t, dst, "stale")
+		if err := c.copyFile("page/image.png", dst, "image.png"); err != nil {
+			t.Fatalf("copyFile %s: %v", dst, err)
+		}
+	}
+	if !sameFile(t, "v1/image.png", "v2/image.png") {
+		t.Error("the archives do not share the object")
+	}
+	fi, err := os.Stat("v1/image.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fi.Mode().Perm(); got != 0o640 {
+		t.Errorf("object mode = %v, want 0640", got)
+	}
+
+	// The page's file changes: the new archive gets a new object
+	writeFile(t, "page/image.png", "edited")
+	if err := os.MkdirAll("v3", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.copyFile("page/image.png", "v3/image.png", "image.png"); err != nil {
+		t.Fatalf("copyFile v3: %v", err)
+	}
+	if b, _ := os.ReadFile("v1/image.png"); string(b) != "image" {
+		t.Errorf("v1/image.png = %q, want image", b)
+	}
+	if sameFile(t, "v1/image.png", "v3/image.png") {
+		t.Error("v3 shares the object of another content")
+	}
+}
+
+func TestCollectGarbage(t *testing.T) {
+	testSite(t, map[string]string{
+		"hugo.toml":             "baseURL = \"https://example.org/\"\n",
+		"content/posts/b/a.png": "kept",
+		"scratch/b.png":         "dropped",
+	})
+	if err := os.MkdirAll("content/posts/b.revisions/v1", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	c := resourceCopier{mode: dedupeStore}
+	if err := c.copyFile("content/posts/b/a.png", "content/posts/b.revisions/v1/a.png", "a.png"); err != nil {
+		t.Fatal(err)
+	}
+	kept, err := storeObject("content/posts/b/a.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dropped, err := storeObject("scratch/b.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := CollectGarbage(true)
+	if err != nil {
+		t.Fatalf("CollectGarbage dry run: %v", err)
+	}
+	if want := []string{dropped}; !slices.Equal(g.Objects, want) || g.Bytes != int64(len("dropped")) {
+		t.Errorf("garbage = %v, %d bytes, want %v, %d bytes", g.Objects, g.Bytes, want, len("dropped"))
+	}
+	if !exists(dropped) {
+		t.Error("the dry run removed an object")
+	}
+
+	if _, err := CollectGarbage(false); err != nil {
+		t.Fatalf("CollectGarbage: %v", err)
+	}
+	if exists(dropped) {
+		t.Error("an unlinked object is left")
+	}
+	if !exists(kept) {
+		t.Error("a linked object was removed")
+	}
+}
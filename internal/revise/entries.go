@@ -0,0 +1,134 @@
+package revise
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ifeitao/hugo-revise/internal/fm"
+	"github.com/ifeitao/hugo-revise/internal/site"
+)
+
+// hasEntries reports whether f holds a structured revisions list. It is
+// written next to revisions_history, which stays the flat list of labels,
+// when versioning.structured_history is set or the page has the list
+// already. Org keywords cannot hold it.
+func hasEntries(f fm.FrontMatter) bool {
+	v, ok := fm.Get(f, "revisions")
+	return ok && v.Kind == fm.ListKind
+}
+
+// archiveURL is the URL of the archived version labelled l.
+func (r *revision) archiveURL(l string) string {
+	return fmt.Sprintf("%srevisions/%s/", r.baseURL, l)
+}
+
+// entriesFor builds the structured revisions list of r's versions once
+// revised. It describes every version, oldest first, so that templates
+// need not rebuild URLs from labels:
+//
+//	revisions:
+//	  - current: false
+//	    date: "2024-06-15T00:00:00+08:00"
+//	    label: "2024-06-15"
+//	    url: /posts/my-post/revisions/2024-06-15/
+//	  - author: Jane Doe
+//	    current: true
+//	    date: "2025-12-01T09:30:00+08:00"
+//	    label: "2025-12-01"
+//	    note: rewritten for v2 API
+//	    url: /posts/my-post/
+//
+// Entries already in the page keep their date and URL; the version being
+// archived moves to its archive URL and the new version gets the page's
+// URL and now.
+func entriesFor(siteCfg site.Config, r *revision, now time.Time) []any {
+	recorded := map[string]map[string]fm.Value{}
+	if v, ok := fm.Get(r.parsed, "revisions"); ok {
+		for _, e := range v.List() {
+			m := e.Map()
+			if l, ok := m["label"]; ok {
+				recorded[l.String()] = m
+			}
+		}
+	}
+	archives := map[string]string{}
+	for _, a := range r.page.Archives() {
+		archives[a.Label] = a.File
+	}
+
+	entries := make([]any, 0, len(r.versions))
+	for _, l := range r.versions {
+		old := recorded[l]
+		e := map[string]any{"label": l}
+		url, date := old["url"].String(), dateString(old["date"])
+		switch l {
+		case r.latest:
+			url, date = r.baseURL, now.Format(dateLayout)
+		case r.version:
+			url = r.archiveURL(l)
+			if date == "" {
+				if t, ok := resolveDate(siteCfg.FrontMatter.Date, r.page.Source, r.parsed, now.Location()); ok {
+					date = t.Format(dateLayout)
+				}
+			}
+		default:
+			if url == "" || date == "" {
+				fileURL, fileDate := archiveInfo(siteCfg, archives[l], now.Location())
+				url = firstNonEmpty(url, fileURL, r.archiveURL(l))
+				date = firstNonEmpty(date, fileDate)
+			}
+		}
+		e["url"] = url
+		if date != "" {
+			e["date"] = date
+		}
+		if note := r.meta["revisions_notes"][l]; note != "" {
+			e["note"] = note
+		}
+		if author := r.meta["revisions_authors"][l]; author != "" {
+			e["author"] = author
+		}
+		e["current"] = l == r.latest
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// dateString formats a date read from the revisions list.
+func dateString(v fm.Value) string {
+	if v.Kind == fm.TimeKind {
+		t, _ := v.Time()
+		return t.Format(dateLayout)
+	}
+	return v.String()
+}
+
+// archiveInfo reads the URL and date of an archived version from its
+// content file.
+func archiveInfo(siteCfg site.Config, file string, loc *time.Location) (url, date string) {
+	if file == "" {
+		return "", ""
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return "", ""
+	}
+	parsed, err := fm.Parse(string(b))
+	if err != nil {
+		return "", ""
+	}
+	if t, ok := resolveDate(siteCfg.FrontMatter.Date, file, parsed, loc); ok {
+		date = t.Format(dateLayout)
+	}
+	return fm.GetValue(parsed, "url"), date
+}
+
+func firstNonEmpty(s ...string) string {
+	for _, v := range s {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -3,20 +3,28 @@ package revise
 import (
 	"encoding/csv"
 	"fmt"
-	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 
 	"github.com/ifeitao/hugo-revise/internal/fm"
+	"github.com/ifeitao/hugo-revise/internal/site"
 )
 
-// getPageURLFromHugo uses hugo list all to get the actual permalink
-func getPageURLFromHugo(bundleDir string, frontMatter fm.FrontMatter) (string, error) {
+// hugoPage is one row of hugo list all.
+type hugoPage struct {
+	Path      string // content file, relative to the project root or in the content tree
+	Permalink string
+	Title     string
+}
+
+// listHugoPages runs hugo list all in the project root and parses its CSV
+// output.
+func listHugoPages(siteCfg site.Config) ([]hugoPage, error) {
 	// Find Hugo project root
-	projectRoot, err := findHugoRoot(bundleDir)
-	if err != nil {
-		return "", err
+	projectRoot := siteCfg.Root
+	if projectRoot == "" {
+		return nil, fmt.Errorf("Hugo project root not found")
 	}
 
 	// Run hugo list all to get CSV output
@@ -24,96 +32,113 @@ func getPageURLFromHugo(bundleDir string, frontMatter fm.FrontMatter) (string, e
 	cmd.Dir = projectRoot
 	output, err := cmd.Output()
 	if err != nil {
-		return "", fmt.Errorf("hugo list all failed: %w", err)
+		return nil, fmt.Errorf("hugo list all failed: %w", err)
 	}
 
 	// Parse CSV output
 	reader := csv.NewReader(strings.NewReader(string(output)))
 	records, err := reader.ReadAll()
 	if err != nil {
-		return "", fmt.Errorf("parse csv failed: %w", err)
+		return nil, fmt.Errorf("parse csv failed: %w", err)
 	}
 
 	// Find header indices
 	if len(records) < 2 {
-		return "", fmt.Errorf("no content found in hugo list all output")
+		return nil, fmt.Errorf("no content found in hugo list all output")
 	}
 
 	header := records[0]
 	pathIdx := -1
 	permalinkIdx := -1
+	titleIdx := -1
 	for i, col := range header {
-		if col == "path" {
+		switch col {
+		case "path":
 			pathIdx = i
-		} else if col == "permalink" {
+		case "permalink":
 			permalinkIdx = i
+		case "title":
+			titleIdx = i
 		}
 	}
 
 	if pathIdx == -1 || permalinkIdx == -1 {
-		return "", fmt.Errorf("required columns not found in hugo list all output")
+		return nil, fmt.Errorf("required columns not found in hugo list all output")
 	}
 
-	// Get relative path from content directory
-	contentPath := filepath.Join(projectRoot, "content")
-	relPath, err := filepath.Rel(contentPath, bundleDir)
+	var pages []hugoPage
+	for _, record := range records[1:] {
+		if len(record) <= pathIdx || len(record) <= permalinkIdx {
+			continue
+		}
+		p := hugoPage{Path: filepath.ToSlash(record[pathIdx]), Permalink: record[permalinkIdx]}
+		if titleIdx >= 0 && titleIdx < len(record) {
+			p.Title = record[titleIdx]
+		}
+		pages = append(pages, p)
+	}
+	return pages, nil
+}
+
+// getPageURLFromHugo uses hugo list all to get the actual permalink
+func getPageURLFromHugo(siteCfg site.Config, sourceFile string, frontMatter fm.FrontMatter) (string, error) {
+	pages, err := listHugoPages(siteCfg)
 	if err != nil {
 		return "", err
 	}
 
-	// Normalize the bundle directory path to match hugo list all output
-	// hugo list all shows index.md for bundles, or .md for single files
-	targetPath := ""
-	indexMd := filepath.Join(bundleDir, "index.md")
-	if _, err := os.Stat(indexMd); err == nil {
-		// It's a bundle, match against path/index.md
-		targetPath = filepath.Join(relPath, "index.md")
-	} else {
-		// Single file .md
-		targetPath = relPath + ".md"
+	// hugo list all shows the content file itself (index.<ext> for
+	// bundles), either relative to the project root or by its path in the
+	// content tree, which differs from the former for mounted content
+	onDisk := relToRoot(siteCfg, sourceFile)
+	logical, _, ok := siteCfg.LogicalPath(sourceFile)
+	if !ok {
+		return "", fmt.Errorf("%s is not inside a content directory", sourceFile)
 	}
 
-	// Search for matching path in records
-	for _, record := range records[1:] {
-		if len(record) <= pathIdx || len(record) <= permalinkIdx {
-			continue
-		}
-
-		recordPath := record[pathIdx]
-		// Normalize path separators for comparison
-		recordPath = filepath.FromSlash(recordPath)
-
-		if strings.Contains(recordPath, targetPath) || strings.HasSuffix(recordPath, targetPath) {
-			permalink := record[permalinkIdx]
-			// Extract path from full URL (remove baseURL)
-			// e.g., https://yifeitao.com/entertainment-unlimited/ -> /entertainment-unlimited/
-			if idx := strings.Index(permalink, "://"); idx != -1 {
-				// Find first / after ://
-				if slashIdx := strings.Index(permalink[idx+3:], "/"); slashIdx != -1 {
-					return permalink[idx+3+slashIdx:], nil
-				}
+	// The path on disk is exact; the logical path is tried once no record
+	// matches it, because per-language content directories may hold files
+	// with the same logical path
+	for _, exact := range []bool{true, false} {
+		for _, p := range pages {
+			if exact && p.Path != onDisk {
+				continue
 			}
-			return permalink, nil
+			if !exact && p.Path != logical && !strings.HasSuffix(p.Path, "/"+logical) {
+				continue
+			}
+			return permalinkPath(p.Permalink), nil
 		}
 	}
 
 	return "", fmt.Errorf("page not found in hugo list all output")
 }
 
-func findHugoRoot(startPath string) (string, error) {
-	dir := startPath
-	for {
-		// Check for Hugo config files
-		for _, name := range []string{"hugo.toml", "hugo.yaml", "hugo.yml", "config.toml", "config.yaml", "config.yml"} {
-			if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
-				return dir, nil
-			}
-		}
-		parent := filepath.Dir(dir)
-		if parent == dir {
-			break
+// relToRoot returns path relative to the project root, with forward slashes.
+func relToRoot(siteCfg site.Config, path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return ""
+	}
+	absRoot, err := filepath.Abs(siteCfg.Root)
+	if err != nil {
+		return ""
+	}
+	rel, err := filepath.Rel(absRoot, abs)
+	if err != nil {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+// permalinkPath removes the scheme and host from a permalink, e.g.
+// https://yifeitao.com/entertainment-unlimited/ -> /entertainment-unlimited/
+func permalinkPath(permalink string) string {
+	if idx := strings.Index(permalink, "://"); idx != -1 {
+		// Find first / after ://
+		if slashIdx := strings.Index(permalink[idx+3:], "/"); slashIdx != -1 {
+			return permalink[idx+3+slashIdx:]
 		}
-		dir = parent
 	}
-	return "", fmt.Errorf("Hugo project root not found")
+	return permalink
 }
@@ -0,0 +1,208 @@
+package revise
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/ifeitao/hugo-revise/internal/clock"
+	"github.com/ifeitao/hugo-revise/internal/config"
+	"github.com/ifeitao/hugo-revise/internal/content"
+	"github.com/ifeitao/hugo-revise/internal/fm"
+	"github.com/ifeitao/hugo-revise/internal/site"
+	"github.com/ifeitao/hugo-revise/internal/storage"
+)
+
+// manifests are the manifests of the revisions directories an operation
+// changes, by path.
+type manifests map[string]*content.Manifest
+
+// open loads the manifest of p's revisions directory, made from the
+// archives on disk when there is none yet, and logs in ops how to put back
+// the one there was.
+func (ms manifests) open(p content.Page, ops *lastOp) (*content.Manifest, error) {
+	path := p.ManifestPath()
+	if m, ok := ms[path]; ok {
+		return m, nil
+	}
+	m, ok, err := content.ReadManifest(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if ok {
+		b, _ := os.ReadFile(path)
+		ops.Originals[path] = string(b)
+	} else {
+		m = scannedManifest(p)
+	}
+	ops.Changes = append(ops.Changes, change{Source: path, Target: path, Action: "manifest"})
+	ms[path] = &m
+	return &m, nil
+}
+
+// write stores every manifest, creating the directories that do not exist
+// yet.
+func (ms manifests) write() error {
+	for path, m := range ms {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := m.Write(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scannedManifest makes a manifest from the archives on disk in p's
+// revisions directory. When the page has a revisions_history, only the
+// labels it lists are versions, so that a stray content file is left out.
+// URL, note, author and body hash are read from each archive; the time it
+// was archived is taken from the file.
+func scannedManifest(p content.Page) content.Manifest {
+	var history []string
+	if b, err := os.ReadFile(p.Source); err == nil {
+		if parsed, err := fm.Parse(string(b)); err == nil {
+			history = fm.GetList(parsed, "revisions_history")
+		}
+	}
+	var m content.Manifest
+	for _, v := range p.ScanVersions() {
+		if len(history) > 0 && !slices.Contains(history, v.Label) {
+			continue
+		}
+		file := filepath.Join(p.RevisionsDir(), filepath.FromSlash(v.Path))
+		if fi, err := os.Stat(file); err == nil {
+			v.Created = fi.ModTime().Format(time.RFC3339)
+		}
+		if b, err := os.ReadFile(file); err == nil {
+			if parsed, err := fm.Parse(string(b)); err == nil {
+				v.URL = fm.GetValue(parsed, "url")
+				v.SHA256 = bodyHash(parsed.Content)
+				v.Note = fm.GetValue(parsed, "revision_note")
+				v.Author = displayName(fm.GetValue(parsed, "revision_author"))
+			}
+		}
+		m.Put(v)
+	}
+	return m
+}
+
+// versionOf describes the version r archives, at now.
+func versionOf(r *revision, now time.Time) content.Version {
+	rel, _ := filepath.Rel(r.page.RevisionsDir(), r.page.ArchiveFile(r.version))
+	return content.Version{
+		Label:   r.version,
+		Lang:    r.page.Lang,
+		Path:    filepath.ToSlash(rel),
+		URL:     r.archiveURL(r.version),
+		Created: now.Format(time.RFC3339),
+		SHA256:  bodyHash(r.parsed.Content),
+		Note:    r.meta["revisions_notes"][r.version],
+		Author:  displayName(r.meta["revisions_authors"][r.version]),
+	}
+}
+
+// bodyHash is the hex SHA-256 of a page body.
+func bodyHash(body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return hex.EncodeToString(sum[:])
+}
+
+// RebuildManifest makes the manifest of the revisions directory of the page
+// at pathPrefix again from the archives on disk, in every language. The
+// times versions were archived are kept from the manifest there was. It
+// returns the versions found.
+func RebuildManifest(cfg config.Config, pathPrefix string) ([]content.Version, error) {
+	if err := config.EnsureLogDir(); err != nil {
+		return nil, err
+	}
+	siteCfg, err := site.Discover(filepath.Dir(filepath.Clean(pathPrefix)))
+	if err != nil {
+		return nil, err
+	}
+	pathPrefix, err = locate(siteCfg, pathPrefix, Options{})
+	if err != nil {
+		return nil, err
+	}
+	page, err := content.Resolve(pathPrefix, siteCfg.LanguageCodes())
+	if err != nil {
+		return nil, err
+	}
+	layout, err := storage.New(cfg.Storage, siteCfg)
+	if err != nil {
+		return nil, err
+	}
+	page = page.WithLayout(layout)
+
+	loc, err := clock.Location(cfg.Versioning.Timezone, siteCfg.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+	now, err := clock.Now(loc)
+	if err != nil {
+		return nil, err
+	}
+
+	ops := lastOp{Timestamp: now.Format(time.RFC3339), Command: "manifest", Originals: map[string]string{}}
+	ms := manifests{}
+	for _, p := range page.Translations() {
+		path := p.ManifestPath()
+		if _, ok := ms[path]; ok {
+			continue
+		}
+		if _, err := os.Stat(p.RevisionsDir()); err != nil {
+			continue
+		}
+		old, ok, err := content.ReadManifest(path)
+		if err != nil {
+			old, ok = content.Manifest{}, false
+		}
+		if b, err := os.ReadFile(path); err == nil {
+			ops.Originals[path] = string(b)
+		}
+		ops.Changes = append(ops.Changes, change{Source: path, Target: path, Action: "manifest"})
+		m := scannedManifest(p)
+		for i, v := range m.Versions {
+			if prev, found := old.Find(v.Label, v.Lang); ok && found && prev.Created != "" {
+				m.Versions[i].Created = prev.Created
+			}
+		}
+		ms[path] = &m
+	}
+	if len(ms) == 0 {
+		return nil, fmt.Errorf("%s has no revisions directory", page.Source)
+	}
+	// Archives replaced by the previous revision can no longer be restored
+	if err := os.RemoveAll(overwrittenDir); err != nil {
+		return nil, err
+	}
+	if err := ms.write(); err != nil {
+		return nil, err
+	}
+	// Revisions directories made before they were hidden are hidden now
+	for _, p := range page.Translations() {
+		if _, ok := ms[p.ManifestPath()]; !ok {
+			continue
+		}
+		changes, err := hideRevisions(hiddenIndexes(siteCfg, p), p.Source)
+		ops.Changes = append(ops.Changes, changes...)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var found []content.Version
+	for _, m := range ms {
+		found = append(found, m.Versions...)
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].Path < found[j].Path })
+	logPath := filepath.Join(config.LogDirectory, "last_op.json")
+	jb, _ := json.MarshalIndent(ops, "", "  ")
+	return found, os.WriteFile(logPath, jb, 0o644)
+}
@@ -0,0 +1,301 @@
+package revise
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ifeitao/hugo-revise/internal/clock"
+	"github.com/ifeitao/hugo-revise/internal/config"
+	"github.com/ifeitao/hugo-revise/internal/content"
+	"github.com/ifeitao/hugo-revise/internal/fm"
+	"github.com/ifeitao/hugo-revise/internal/label"
+	"github.com/ifeitao/hugo-revise/internal/site"
+	"github.com/ifeitao/hugo-revise/internal/storage"
+)
+
+// RelabelOptions are the command-line choices for renaming a version.
+type RelabelOptions struct {
+	Alias bool // keep the old URL of the archive working through aliases
+}
+
+// relabelFile is a content file rewritten by a relabel, found at from and
+// written to to once its archive has been moved.
+type relabelFile struct {
+	from, to string
+	original []byte
+	parsed   fm.FrontMatter
+	oldURL   string // URL of the relabelled archive before and after
+	newURL   string
+	archive  bool         // the file is the relabelled archive
+	page     content.Page // translation the file belongs to
+	edited   string       // the file as written, once edited
+}
+
+// Relabel renames the version labelled from to to, in every language of
+// the page at pathPrefix: translations revised together share their labels,
+// and bundle archives of one label share a directory. The archive is moved,
+// its url follows the new label, and the history of the page and all its
+// archives is rewritten. Relabelling the current version only rewrites the
+// history.
+func Relabel(cfg config.Config, pathPrefix, from, to string, opts RelabelOptions) error {
+	if err := config.EnsureLogDir(); err != nil {
+		return err
+	}
+	if err := label.Check(to); err != nil {
+		return err
+	}
+
+	siteCfg, err := site.Discover(filepath.Dir(filepath.Clean(pathPrefix)))
+	if err != nil {
+		return err
+	}
+	pathPrefix, err = locate(siteCfg, pathPrefix, Options{})
+	if err != nil {
+		return err
+	}
+	page, err := content.Resolve(pathPrefix, siteCfg.LanguageCodes())
+	if err != nil {
+		return err
+	}
+	layout, err := storage.New(cfg.Storage, siteCfg)
+	if err != nil {
+		return err
+	}
+	page = page.WithLayout(layout)
+	strategy, err := label.New(cfg.Versioning)
+	if err != nil {
+		return err
+	}
+	loc, err := clock.Location(cfg.Versioning.Timezone, siteCfg.TimeZone)
+	if err != nil {
+		return err
+	}
+	now, err := clock.Now(loc)
+	if err != nil {
+		return err
+	}
+
+	// Plan every move and rewrite before touching any file
+	var moves []change
+	var files []*relabelFile
+	for _, p := range page.Translations() {
+		planned, err := planRelabel(strategy, p, from, to, &moves)
+		if err != nil {
+			return err
+		}
+		files = append(files, planned...)
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("%s has no version labelled %s", page.Source, from)
+	}
+
+	// Every file is edited before any is moved or written
+	for _, f := range files {
+		parsed, err := relabelled(f.parsed, from, to, f.oldURL, f.newURL)
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.from, err)
+		}
+		if f.archive && f.newURL != f.oldURL {
+			if parsed, err = fm.InjectKV(parsed, "url", f.newURL); err != nil {
+				return fmt.Errorf("%s: %w", f.from, err)
+			}
+			if aliases := fm.GetList(parsed, "aliases"); opts.Alias && !slices.Contains(aliases, f.oldURL) {
+				if parsed, err = fm.InjectList(parsed, "aliases", append(aliases, f.oldURL)); err != nil {
+					return fmt.Errorf("%s: %w", f.from, err)
+				}
+			}
+		}
+		f.edited = fm.Stringify(parsed)
+	}
+
+	ops := lastOp{Timestamp: now.Format(time.RFC3339), Command: "relabel", Originals: map[string]string{}}
+	// The manifests are read before anything moves
+	ms := manifests{}
+	var listed []content.Page
+	for _, f := range files {
+		if !exists(f.page.RevisionsDir()) {
+			continue
+		}
+		if _, err := ms.open(f.page, &ops); err != nil {
+			return err
+		}
+		listed = append(listed, f.page)
+	}
+	// Archives replaced by the previous revision can no longer be restored
+	if err := os.RemoveAll(overwrittenDir); err != nil {
+		return err
+	}
+	for _, m := range moves {
+		if err := os.Rename(m.Source, m.Target); err != nil {
+			return fmt.Errorf("move archive: %w", err)
+		}
+	}
+	ops.Changes = append(ops.Changes, moves...)
+	for _, f := range files {
+		if err := os.WriteFile(f.to, []byte(f.edited), 0o644); err != nil {
+			return err
+		}
+		ops.Originals[f.from] = string(f.original)
+		ops.Changes = append(ops.Changes, change{Source: f.from, Target: f.to, Action: "write"})
+		if f.archive {
+			renameVersion(ms[f.page.ManifestPath()], f, to)
+		}
+	}
+	if err := ms.write(); err != nil {
+		return err
+	}
+	// Revisions directories made before they were hidden are hidden now
+	for _, p := range listed {
+		changes, err := hideRevisions(hiddenIndexes(siteCfg, p), p.Source)
+		ops.Changes = append(ops.Changes, changes...)
+		if err != nil {
+			return err
+		}
+	}
+
+	logPath := filepath.Join(config.LogDirectory, "last_op.json")
+	jb, _ := json.MarshalIndent(ops, "", "  ")
+	return os.WriteFile(logPath, jb, 0o644)
+}
+
+// planRelabel lists the files of one translation a relabel rewrites: the
+// page and its archives. The move of the relabelled archive is added to
+// moves unless another translation's plan holds it already. A translation
+// without the version is left alone.
+func planRelabel(strategy label.Strategy, p content.Page, from, to string, moves *[]change) ([]*relabelFile, error) {
+	b, err := os.ReadFile(p.Source)
+	if err != nil {
+		return nil, fmt.Errorf("read source file: %w", err)
+	}
+	parsed, err := fm.Parse(string(b))
+	if err != nil {
+		return nil, err
+	}
+	versions := withLabels(strategy, p, fm.GetList(parsed, "revisions_history")...)
+	i := slices.Index(versions, from)
+	if i < 0 {
+		return nil, nil
+	}
+	if slices.Contains(versions, to) {
+		return nil, fmt.Errorf("%s already has a version labelled %s", p.Source, to)
+	}
+	// The version keeps its place in revisions_history
+	if i > 0 && !strategy.Less(versions[i-1], to) {
+		return nil, fmt.Errorf("label %s would not come after %s, the version before %s of %s", to, versions[i-1], from, p.Source)
+	}
+	if i < len(versions)-1 && !strategy.Less(to, versions[i+1]) {
+		return nil, fmt.Errorf("label %s would not come before %s, the version after %s of %s", to, versions[i+1], from, p.Source)
+	}
+
+	files := []*relabelFile{{from: p.Source, to: p.Source, original: b, parsed: parsed, page: p}}
+	for _, a := range p.Archives() {
+		f := &relabelFile{from: a.File, to: a.File, page: p}
+		if a.Label == from {
+			src, dst := p.ArchivePath(from), p.ArchivePath(to)
+			if !slices.ContainsFunc(*moves, func(c change) bool { return c.Source == src }) {
+				if exists(dst) {
+					return nil, fmt.Errorf("cannot relabel %s to %s: %s already exists", from, to, dst)
+				}
+				*moves = append(*moves, change{Source: src, Target: dst, Action: "move"})
+			}
+			f.to, f.archive = p.ArchiveFile(to), true
+		}
+		if a.File == "" {
+			continue
+		}
+		if f.original, err = os.ReadFile(a.File); err != nil {
+			return nil, err
+		}
+		if f.parsed, err = fm.Parse(string(f.original)); err != nil {
+			return nil, fmt.Errorf("%s: %w", a.File, err)
+		}
+		files = append(files, f)
+	}
+
+	// The archive's URL follows its label unless it was set by hand
+	for _, f := range files {
+		if !f.archive {
+			continue
+		}
+		oldURL := fm.GetValue(f.parsed, "url")
+		newURL := oldURL
+		if suffix := "/revisions/" + from + "/"; strings.HasSuffix(oldURL, suffix) {
+			newURL = strings.TrimSuffix(oldURL, suffix) + "/revisions/" + to + "/"
+		}
+		for _, g := range files {
+			g.oldURL, g.newURL = oldURL, newURL
+		}
+	}
+	return files, nil
+}
+
+// renameVersion gives the version archived in f its new label, path and
+// URL in m.
+func renameVersion(m *content.Manifest, f *relabelFile, to string) {
+	dir := f.page.RevisionsDir()
+	from, _ := filepath.Rel(dir, f.from)
+	for i, v := range m.Versions {
+		if v.Path != filepath.ToSlash(from) {
+			continue
+		}
+		rel, _ := filepath.Rel(dir, f.to)
+		m.Versions[i].Label, m.Versions[i].Path = to, filepath.ToSlash(rel)
+		if v.URL == f.oldURL {
+			m.Versions[i].URL = f.newURL
+		}
+	}
+}
+
+// relabelled returns f with the version from renamed to in
+// revisions_history, the maps of versionFields and the structured
+// revisions list, where its URL moves from oldURL to newURL.
+func relabelled(f fm.FrontMatter, from, to, oldURL, newURL string) (fm.FrontMatter, error) {
+	var err error
+	if history := fm.GetList(f, "revisions_history"); len(history) > 0 {
+		for i, l := range history {
+			if l == from {
+				history[i] = to
+			}
+		}
+		if f, err = fm.InjectList(f, "revisions_history", history); err != nil {
+			return f, err
+		}
+	}
+	for _, vf := range versionFields {
+		recorded, ok := fm.GetMap(f, vf.all)
+		if !ok {
+			continue
+		}
+		m := make(map[string]any, len(recorded))
+		for k, v := range recorded {
+			// Hugo and the Org editor may change the case of keys
+			if strings.EqualFold(k, from) {
+				k = to
+			}
+			m[k] = v.String()
+		}
+		if f, err = fm.Set(f, vf.all, m); err != nil {
+			return f, err
+		}
+	}
+	if v, ok := fm.Get(f, "revisions"); ok && v.Kind == fm.ListKind {
+		entries := make([]any, 0, len(v.List()))
+		for _, e := range v.List() {
+			x := e.Interface()
+			if m, ok := x.(map[string]any); ok && e.Map()["label"].String() == from {
+				m["label"] = to
+				if oldURL != "" && e.Map()["url"].String() == oldURL {
+					m["url"] = newURL
+				}
+			}
+			entries = append(entries, x)
+		}
+		return fm.Set(f, "revisions", entries)
+	}
+	return f, nil
+}
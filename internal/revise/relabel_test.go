@@ -0,0 +1,149 @@
+package revise
+
+import (
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ifeitao/hugo-revise/internal/config"
+	"github.com/ifeitao/hugo-revise/internal/content"
+	"github.com/ifeitao/hugo-revise/internal/fm"
+	"github.com/ifeitao/hugo-revise/internal/undo"
+)
+
+// testSite writes files, by path relative to a temporary site root, and
+// makes the root the working directory, where the operation log is kept.
+func testSite(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for path, data := range files {
+		writeFile(t, filepath.Join(root, path), data)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(root); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+	return root
+}
+
+// snapshot returns the files under content, by path, with their contents.
+func snapshot(t *testing.T) map[string]string {
+	t.Helper()
+	files := map[string]string{}
+	err := filepath.WalkDir("content", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		b, err := os.ReadFile(path)
+		files[filepath.ToSlash(path)] = string(b)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestRelabel(t *testing.T) {
+	testSite(t, map[string]string{
+		"hugo.toml": "baseURL = \"https://example.org/\"\n",
+		"content/posts/p.md": "---\ntitle: P\nrevisions_history:\n  - 2024-01-01\n  - 2024-03-01\n" +
+			"revisions_notes:\n  \"2024-01-01\": first\n  \"2024-03-01\": second\n---\nbody\n",
+		"content/posts/p.revisions/2024-01-01.md": "---\ntitle: P\nurl: /posts/p/revisions/2024-01-01/\n" +
+			"revisions_history:\n  - 2024-01-01\n  - 2024-03-01\n---\nold body\n",
+	})
+	cfg, err := config.Load(".hugo-reviserc.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := snapshot(t)
+
+	if err := Relabel(cfg, "content/posts/p.md", "2024-01-01", "2023-12-31", RelabelOptions{Alias: true}); err != nil {
+		t.Fatalf("Relabel: %v", err)
+	}
+	after := snapshot(t)
+	if _, ok := after["content/posts/p.revisions/2024-01-01.md"]; ok {
+		t.Error("the archive was not moved")
+	}
+	archive, err := fm.Parse(after["content/posts/p.revisions/2023-12-31.md"])
+	if err != nil {
+		t.Fatalf("Parse archive: %v", err)
+	}
+	if got, want := fm.GetValue(archive, "url"), "/posts/p/revisions/2023-12-31/"; got != want {
+		t.Errorf("archive url = %q, want %q", got, want)
+	}
+	if got, want := fm.GetList(archive, "aliases"), []string{"/posts/p/revisions/2024-01-01/"}; !slices.Equal(got, want) {
+		t.Errorf("archive aliases = %v, want %v", got, want)
+	}
+	page, err := fm.Parse(after["content/posts/p.md"])
+	if err != nil {
+		t.Fatalf("Parse page: %v", err)
+	}
+	want := []string{"2023-12-31", "2024-03-01"}
+	if got := fm.GetList(page, "revisions_history"); !slices.Equal(got, want) {
+		t.Errorf("page history = %v, want %v", got, want)
+	}
+	if got := fm.GetList(archive, "revisions_history"); !slices.Equal(got, want) {
+		t.Errorf("archive history = %v, want %v", got, want)
+	}
+	if notes, _ := fm.GetMap(page, "revisions_notes"); notes["2023-12-31"].String() != "first" {
+		t.Errorf("page notes = %v, want first under 2023-12-31", notes)
+	}
+	m, ok, err := content.ReadManifest("content/posts/p.revisions/" + content.ManifestName)
+	if !ok || err != nil {
+		t.Fatalf("ReadManifest: %v, %v", ok, err)
+	}
+	if v, ok := m.Find("2023-12-31", ""); !ok || v.Path != "2023-12-31.md" {
+		t.Errorf("manifest version = %+v, %v, want path 2023-12-31.md", v, ok)
+	}
+	if !strings.Contains(after["content/posts/p.revisions/_index.md"], "render: never") {
+		t.Error("the revisions directory was not hidden")
+	}
+
+	if err := undo.Run(cfg); err != nil {
+		t.Fatalf("undo: %v", err)
+	}
+	if got := snapshot(t); !maps.Equal(got, before) {
+		t.Errorf("after undo the content is\n%v\nwant\n%v", got, before)
+	}
+}
+
+func TestRelabelErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to string
+	}{
+		{"unknown label", "2023-01-01", "2023-02-01"},
+		{"label taken", "2024-01-01", "2024-03-01"},
+		{"out of order", "2024-01-01", "2024-06-01"},
+		{"not a path segment", "2024-01-01", "a/b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testSite(t, map[string]string{
+				"hugo.toml":          "baseURL = \"https://example.org/\"\n",
+				"content/posts/p.md": "---\ntitle: P\nrevisions_history: [2024-01-01, 2024-03-01]\n---\nbody\n",
+				"content/posts/p.revisions/2024-01-01.md": "---\ntitle: P\n---\nold body\n",
+			})
+			cfg, err := config.Load(".hugo-reviserc.toml")
+			if err != nil {
+				t.Fatal(err)
+			}
+			before := snapshot(t)
+			if err := Relabel(cfg, "content/posts/p.md", tt.from, tt.to, RelabelOptions{}); err == nil {
+				t.Error("want an error")
+			}
+			if got := snapshot(t); !maps.Equal(got, before) {
+				t.Errorf("the content changed to\n%v", got)
+			}
+		})
+	}
+}
@@ -5,263 +5,609 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"sort"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/ifeitao/hugo-revise/internal/clock"
 	"github.com/ifeitao/hugo-revise/internal/config"
+	"github.com/ifeitao/hugo-revise/internal/content"
 	"github.com/ifeitao/hugo-revise/internal/fm"
+	"github.com/ifeitao/hugo-revise/internal/label"
+	"github.com/ifeitao/hugo-revise/internal/site"
+	"github.com/ifeitao/hugo-revise/internal/storage"
 )
 
 type lastOp struct {
-	Timestamp       string   `json:"timestamp"`
-	OriginalContent string   `json:"original_content"` // Original source file content before revision
-	Changes         []change `json:"changes"`
+	Timestamp   string            `json:"timestamp"`
+	Command     string            `json:"command,omitempty"`      // relabel or update; empty for a revision
+	Message     string            `json:"message,omitempty"`      // revision note of the new version
+	Author      string            `json:"author,omitempty"`       // who made the revision, with their email address
+	OnCollision string            `json:"on_collision,omitempty"` // collision handling applied, if the new label was taken
+	Originals   map[string]string `json:"originals"`              // Source file content before revision, by path
+	Changes     []change          `json:"changes"`
 }
 
 type change struct {
 	Source string `json:"source"`
 	Target string `json:"target"`
-	Action string `json:"action"` // copy, move, write
+	Action string `json:"action"` // copy, move, write, restore, manifest
 }
 
-func Run(cfg config.Config, pathPrefix string) error {
+// Options are the command-line choices for a revision.
+type Options struct {
+	AllLanguages bool   // revise every translation of the page, the new versions under one label
+	URL          string // select the page by its public URL instead of a path
+	Title        string // select the page by its title instead of a path
+	Bump         string // semver part to bump: major, minor or patch
+	OnCollision  string // overrides versioning.on_collision
+	Label        string // label of the new version instead of the strategy's
+	ArchiveLabel string // label of the archived version instead of the strategy's
+	Date         string // date of the revision instead of now, for backdated revisions
+	Message      string // revision note: why the page was revised
+	Author       string // who makes the revision, instead of HUGO_REVISE_AUTHOR or git's user
+	Force        bool   // archive even when the content hardly changed
+}
+
+// revision is one page being revised.
+type revision struct {
+	page     content.Page
+	original []byte
+	parsed   fm.FrontMatter
+	version  string                       // label of the archived version
+	latest   string                       // label of the new current version
+	versions []string                     // labels of all versions, oldest first, once revised
+	meta     map[string]map[string]string // maps of versionFields, once revised
+	baseURL  string                       // URL of the current version, ending in /
+	entries  []any                        // structured revisions list, when written
+
+	amend     bool     // archive nothing, the label is taken by the current version
+	overwrite bool     // replace the archive that already has the label
+	hidden    []string // _index files keeping the revisions out of the published site
+
+	// The files the revision writes, once edited
+	archived   fm.FrontMatter    // the archived version
+	updated    fm.FrontMatter    // the page itself
+	propagated map[string]string // archives with the new history, by path
+}
+
+func Run(cfg config.Config, pathPrefix string, opts Options) error {
 	if err := config.EnsureLogDir(); err != nil {
 		return err
 	}
 
-	var sourceFile string
-	var isBundle bool
-	var bundleName string
+	siteCfg, err := site.Discover(filepath.Dir(filepath.Clean(pathPrefix)))
+	if err != nil {
+		return err
+	}
+	pathPrefix, err = locate(siteCfg, pathPrefix, opts)
+	if err != nil {
+		return err
+	}
+	page, err := content.Resolve(pathPrefix, siteCfg.LanguageCodes())
+	if err != nil {
+		return err
+	}
+	layout, err := storage.New(cfg.Storage, siteCfg)
+	if err != nil {
+		return err
+	}
+	page = page.WithLayout(layout)
+	pages := []content.Page{page}
+	if opts.AllLanguages {
+		pages = page.Translations()
+	}
 
-	// Smart path detection
-	if strings.HasSuffix(pathPrefix, ".md") {
-		// Explicit .md file
-		sourceFile = pathPrefix
-		isBundle = false
-		bundleName = strings.TrimSuffix(filepath.Base(pathPrefix), ".md")
-	} else {
-		// Could be either a bundle directory or a file name without extension
-		// Try as bundle first (check for index.md)
-		bundleIndexPath := filepath.Join(pathPrefix, "index.md")
-		if _, err := os.Stat(bundleIndexPath); err == nil {
-			// It's a bundle with index.md
-			sourceFile = bundleIndexPath
-			isBundle = true
-			bundleName = filepath.Base(pathPrefix)
-		} else {
-			// Try adding .md extension
-			mdPath := pathPrefix + ".md"
-			if _, err := os.Stat(mdPath); err == nil {
-				// It's a single .md file
-				sourceFile = mdPath
-				isBundle = false
-				bundleName = filepath.Base(pathPrefix)
-			} else {
-				return fmt.Errorf("source not found: tried %s and %s", bundleIndexPath, mdPath)
+	strategy, err := label.New(cfg.Versioning)
+	if err != nil {
+		return err
+	}
+	if opts.Bump != "" && cfg.Versioning.Label != "semver" {
+		return fmt.Errorf("--bump only applies to versioning.label = \"semver\"")
+	}
+	mode, err := collisionMode(cfg, opts)
+	if err != nil {
+		return err
+	}
+	minorMode, err := minorChangeMode(cfg)
+	if err != nil {
+		return err
+	}
+	dedupe, err := dedupeMode(cfg)
+	if err != nil {
+		return err
+	}
+	for _, l := range []string{opts.Label, opts.ArchiveLabel} {
+		if l == "" {
+			continue
+		}
+		if err := label.Check(l); err != nil {
+			return err
+		}
+	}
+	// The clock is read once: every label, date and the log agree on it
+	loc, err := clock.Location(cfg.Versioning.Timezone, siteCfg.TimeZone)
+	if err != nil {
+		return err
+	}
+	now, err := clock.Now(loc)
+	if err != nil {
+		return err
+	}
+	// The revision happens at when: now, or the date given with --date
+	when := now
+	if opts.Date != "" {
+		if when, err = fm.ParseTimeIn(opts.Date, loc); err != nil {
+			return fmt.Errorf("--date: %w", err)
+		}
+		when = when.In(loc)
+	}
+
+	// Label every page before touching any of them
+	var revisions []*revision
+	for _, p := range pages {
+		r, err := prepare(siteCfg, strategy, p, when, opts)
+		if err != nil {
+			return err
+		}
+		revisions = append(revisions, r)
+	}
+	// Translations revised together share the new version's label, taken
+	// from the first of them (the default language). Each archives its
+	// current version under the label its own history gives it.
+	for _, r := range revisions[1:] {
+		r.latest = revisions[0].latest
+		r.versions = withLabels(strategy, r.page, r.version, r.latest)
+	}
+	// A label given by hand is used as it is or not at all
+	for _, r := range revisions {
+		if opts.Label != "" && collides(r) {
+			return fmt.Errorf("--label %s: %s already has a version with that label", opts.Label, r.page.Source)
+		}
+	}
+	collided, err := resolveCollisions(cfg, strategy, revisions, mode, when)
+	if err != nil {
+		return err
+	}
+	if opts.Label != "" || opts.ArchiveLabel != "" || opts.Date != "" || len(revisions) > 1 {
+		for _, r := range revisions {
+			if err := checkOrder(strategy, r); err != nil {
+				return err
 			}
 		}
 	}
 
-	// Verify source file exists (redundant check but clear error message)
-	if _, err := os.Stat(sourceFile); os.IsNotExist(err) {
-		return fmt.Errorf("source file not found: %s", sourceFile)
+	// Only versions that differ enough from the latest archive are archived
+	for _, r := range revisions {
+		if err := checkSignificance(cfg, strategy, minorMode, r, opts.Force); err != nil {
+			return err
+		}
+	}
+
+	author, contact := resolveAuthor(opts.Author, siteCfg.Root)
+	own := map[string]string{"revision_note": opts.Message, "revision_author": author}
+	for _, r := range revisions {
+		r.meta = metaFor(r, own)
+		r.baseURL = extractBaseURL(r.parsed, r.page, siteCfg)
+		r.hidden = hiddenIndexes(siteCfg, r.page)
+		if cfg.Versioning.StructuredHistory || hasEntries(r.parsed) {
+			r.entries = entriesFor(siteCfg, r, when)
+		}
+	}
+
+	// Every front matter is edited before any file is written, so that an
+	// edit that fails leaves the site as it was
+	for _, r := range revisions {
+		if err := r.edit(when, own); err != nil {
+			return err
+		}
+	}
+
+	ops := lastOp{Timestamp: now.Format(time.RFC3339), Message: opts.Message, Author: contact, Originals: map[string]string{}}
+	if collided {
+		ops.OnCollision = mode
+		// An overwrite that found only current versions' labels amended them
+		if !slices.ContainsFunc(revisions, func(r *revision) bool { return !r.amend }) {
+			ops.OnCollision = collisionAmend
+		}
+	}
+	// Archives replaced by the previous revision can no longer be restored
+	if err := os.RemoveAll(overwrittenDir); err != nil {
+		return err
+	}
+	restores, err := backupArchives(revisions)
+	if err != nil {
+		return err
+	}
+	// The manifests of the revisions directories list the versions archived
+	ms := manifests{}
+	for _, r := range revisions {
+		if r.amend {
+			continue
+		}
+		if _, err := ms.open(r.page, &ops); err != nil {
+			return err
+		}
+	}
+	for _, r := range revisions {
+		var changes []change
+		if r.amend {
+			changes, err = amend(r)
+		} else {
+			changes, err = archive(r, copierFor(dedupe, strategy, r))
+		}
+		if err != nil {
+			return err
+		}
+		// Save original content for undo
+		ops.Originals[r.page.Source] = string(r.original)
+		ops.Changes = append(ops.Changes, changes...)
+	}
+	ops.Changes = append(ops.Changes, restores...)
+	for _, r := range revisions {
+		if !r.amend {
+			ms[r.page.ManifestPath()].Put(versionOf(r, now))
+		}
+	}
+	if err := ms.write(); err != nil {
+		return err
+	}
+
+	// Log operations
+	logPath := filepath.Join(config.LogDirectory, "last_op.json")
+	jb, _ := json.MarshalIndent(ops, "", "  ")
+	if err := os.WriteFile(logPath, jb, 0o644); err != nil {
+		return err
 	}
+	return nil
+}
+
+// prepare reads a page and labels its archived and new versions. now is
+// the time of the revision, in the zone labels are made in.
+func prepare(siteCfg site.Config, strategy label.Strategy, page content.Page, now time.Time, opts Options) (*revision, error) {
+	sourceFile := page.Source
 
 	// Read source content
 	b, err := os.ReadFile(sourceFile)
 	if err != nil {
-		return fmt.Errorf("read source file: %w", err)
+		return nil, fmt.Errorf("read source file: %w", err)
 	}
 	parsed, err := fm.Parse(string(b))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Create revisions directory (e.g., my-post.revisions/ or my-post-bundle.revisions/)
-	baseDir := filepath.Dir(pathPrefix)
-	revisionsDir := filepath.Join(baseDir, bundleName+".revisions")
-	if err := os.MkdirAll(revisionsDir, 0o755); err != nil {
-		return err
+	if logical, _, ok := siteCfg.LogicalPath(page.Path); page.Branch && ok && logical == "" {
+		return nil, fmt.Errorf("%s is the home page; its archives would fall outside the content directory, so it cannot be revised", sourceFile)
 	}
 
-	// Determine version labels
-	baseDate := extractDocumentDate(parsed, cfg.Versioning.DateFormat)
-	currentDate := time.Now().Format(cfg.Versioning.DateFormat)
+	fields := map[string]any{}
+	for k, v := range fm.Values(parsed) {
+		fields[k] = v.Interface()
+	}
 
-	// Check if a revision for today already exists
-	existingEntries, _ := os.ReadDir(revisionsDir)
-	for _, e := range existingEntries {
-		name := e.Name()
-		var versionLabel string
-		if isBundle {
-			if e.IsDir() {
-				versionLabel = name
-			}
-		} else {
-			if !e.IsDir() && strings.HasSuffix(name, ".md") {
-				versionLabel = strings.TrimSuffix(name, ".md")
-			}
+	// Archive the old content under the label it was given, or one made
+	// from its original date. A label given by hand cannot rename a version
+	// already in revisions_history: its note and author would be lost.
+	version := opts.ArchiveLabel
+	if version == "" {
+		if version, err = extractDocumentLabel(siteCfg, strategy, sourceFile, parsed, fields, now); err != nil {
+			return nil, err
 		}
-		if versionLabel == currentDate {
-			return fmt.Errorf("a revision for %s already exists. hugo-revise is designed for major revisions, not daily updates. Please use git for granular version control, or wait until a different day to create another revision", currentDate)
+	} else if slices.ContainsFunc(page.Archives(), func(a content.Archive) bool { return a.Label == version }) {
+		return nil, fmt.Errorf("--archive-label %s: %s already has an archive with that label", version, sourceFile)
+	} else if history := fm.GetList(parsed, "revisions_history"); len(history) > 0 {
+		if current := label.Latest(strategy, history); current != version {
+			return nil, fmt.Errorf("--archive-label %s: the current version of %s is labelled %s already; rename it with hugo-revise relabel first", version, sourceFile, current)
 		}
 	}
+	existing := withLabels(strategy, page, version)
 
-	// Archive the old content with its original date
-	version := baseDate
-	// Current version uses today's date
-	newLatestLabel := currentDate
-
-	// Create archived target
-	var archivedFile string
-	var archivedDir string
-	if isBundle {
-		archivedDir = filepath.Join(revisionsDir, version)
-		if err := os.MkdirAll(archivedDir, 0o755); err != nil {
-			return err
+	// A backdated revision cannot come before the version it archives
+	if opts.Date != "" {
+		if date, ok := resolveDate(siteCfg.FrontMatter.Date, sourceFile, parsed, now.Location()); ok && now.Before(date) {
+			return nil, fmt.Errorf("--date %s is before %s, the date of the current version of %s", now.Format(time.RFC3339), date.Format(time.RFC3339), sourceFile)
 		}
-		archivedFile = filepath.Join(archivedDir, "index.md")
-	} else {
-		archivedFile = filepath.Join(revisionsDir, version+".md")
 	}
 
-	// Prepare archived content
-	archivedFM := parsed
+	// Current version gets the strategy's next label
+	latest := opts.Label
+	if latest == "" {
+		if latest, err = strategy.Next(label.Context{Date: now, Counter: len(existing) + 1, Page: fields, Bump: opts.Bump}, existing); err != nil {
+			return nil, err
+		}
+	}
+	r := &revision{page: page, original: b, parsed: parsed, version: version, latest: latest}
+	r.versions = withLabels(strategy, page, version, latest)
+	return r, nil
+}
 
-	// Determine base URL
-	var baseURL string
-	if isBundle {
-		baseURL = extractBaseURL(parsed, pathPrefix)
-	} else {
-		baseURL = extractBaseURL(parsed, filepath.Join(baseDir, bundleName))
+// withLabels returns the labels of the page's archives plus extra, without
+// duplicates, oldest first.
+func withLabels(strategy label.Strategy, page content.Page, extra ...string) []string {
+	seen := map[string]bool{}
+	var versions []string
+	for _, a := range page.Archives() {
+		seen[a.Label] = true
+		versions = append(versions, a.Label)
+	}
+	for _, l := range extra {
+		if !seen[l] {
+			seen[l] = true
+			versions = append(versions, l)
+		}
 	}
+	label.Sort(strategy, versions)
+	return versions
+}
 
-	archiveURL := fmt.Sprintf("%srevisions/%s/", baseURL, version)
+// checkOrder makes sure a revision with labels or a date given by hand
+// keeps revisions_history in order: the archived version must come after
+// the earlier archives and the new version after all of them.
+func checkOrder(strategy label.Strategy, r *revision) error {
+	if r.amend {
+		return nil
+	}
+	for _, a := range r.page.Archives() {
+		if r.overwrite && a.Label == r.version {
+			continue
+		}
+		if !strategy.Less(a.Label, r.version) {
+			return fmt.Errorf("label %s of the archived version would not come after the archive %s of %s", r.version, a.Label, r.page.Source)
+		}
+	}
+	if !strategy.Less(r.version, r.latest) {
+		return fmt.Errorf("label %s of the new version would not come after %s, the archived version of %s", r.latest, r.version, r.page.Source)
+	}
+	return nil
+}
 
-	// Set fixed URL for archived version
-	archivedFM, _ = fm.InjectKV(archivedFM, "url", archiveURL)
-	archivedFM, _ = fm.InjectBuildOptions(archivedFM)
+// edit makes the front matter r writes: the archived version, the page
+// itself and, when their history changes, its archives. own holds the
+// versionFields of the new version. Nothing is written yet.
+func (r *revision) edit(now time.Time, own map[string]string) error {
+	var err error
+	if !r.amend {
+		// Set fixed URL for archived version, and keep it out of lists
+		archived, err := fm.InjectKV(r.parsed, "url", r.archiveURL(r.version))
+		if err != nil {
+			return fmt.Errorf("%s: %w", r.page.Source, err)
+		}
+		if archived, err = fm.InjectBuildOptions(archived); err != nil {
+			return fmt.Errorf("%s: %w", r.page.Source, err)
+		}
+		// revisions_history: archived versions + current, in label order.
+		// The archive keeps its own revision_note.
+		if r.archived, err = withHistory(archived, r); err != nil {
+			return fmt.Errorf("%s: %w", r.page.Source, err)
+		}
+	}
 
-	// Build revisions_history: scan archived versions + current
-	var versions []string
-	entries, _ := os.ReadDir(revisionsDir)
-	for _, e := range entries {
-		name := e.Name()
-		if isBundle {
-			if e.IsDir() {
-				versions = append(versions, name)
-			}
-		} else {
-			if !e.IsDir() && strings.HasSuffix(name, ".md") {
-				versions = append(versions, strings.TrimSuffix(name, ".md"))
-			}
+	// Update lastmod and date to current time (unquoted, RFC3339 format for Hugo compatibility)
+	currentDateTime := now.Format(dateLayout)
+	updated, err := fm.InjectKVUnquoted(r.parsed, "lastmod", currentDateTime)
+	if err != nil {
+		return fmt.Errorf("%s: %w", r.page.Source, err)
+	}
+	if updated, err = fm.InjectKVUnquoted(updated, "date", currentDateTime); err != nil {
+		return fmt.Errorf("%s: %w", r.page.Source, err)
+	}
+	if updated, err = withHistory(updated, r); err != nil {
+		return fmt.Errorf("%s: %w", r.page.Source, err)
+	}
+	// The new version has its own note and author or none; an amended one
+	// keeps its own unless new ones are given
+	changed := !r.amend || r.entries != nil
+	for _, vf := range versionFields {
+		switch {
+		case own[vf.own] != "":
+			updated, err = fm.Set(updated, vf.own, own[vf.own])
+			changed = true
+		case !r.amend:
+			updated, err = fm.RemoveKey(updated, vf.own)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", r.page.Source, err)
 		}
 	}
-	// Ensure archived version present
-	found := false
-	for _, v := range versions {
-		if v == version {
-			found = true
-			break
+	r.updated = updated
+
+	// Every historical version page gets the same, up-to-date history
+	if changed {
+		if r.propagated, err = propagated(r); err != nil {
+			return err
 		}
 	}
-	if !found {
-		versions = append(versions, version)
+	return nil
+}
+
+// archive writes the archived copy of one page, the history of its archives
+// and the page itself, as edited, and returns the changes made.
+func archive(r *revision, resources resourceCopier) ([]change, error) {
+	page := r.page
+	sourceFile := page.Source
+	version := r.version
+
+	// Create revisions directory (e.g., my-post.revisions/ or my-post-bundle.revisions/)
+	changes, err := hideRevisions(r.hidden, page.Source)
+	if err != nil {
+		return nil, err
+	}
+	revisionsDir := page.RevisionsDir()
+	if err := os.MkdirAll(revisionsDir, 0o755); err != nil {
+		return nil, err
+	}
+
+	// Create archived target. A bundle archive directory may already hold
+	// another language's archive for the same label.
+	archivedFile := page.ArchiveFile(version)
+	archivedDir := filepath.Dir(archivedFile)
+	_, statErr := os.Stat(archivedDir)
+	dirExisted := statErr == nil
+	if err := os.MkdirAll(archivedDir, 0o755); err != nil {
+		return nil, err
 	}
-	// Add current version label
-	versions = append(versions, newLatestLabel)
-	// Sort chronologically (dates sort naturally)
-	sort.Strings(versions)
-	// Inject revisions_history as YAML/TOML list
-	archivedFM, _ = fm.InjectList(archivedFM, "revisions_history", versions)
 
 	// Write archived file
-	if err := os.WriteFile(archivedFile, []byte(fm.Stringify(archivedFM)), 0o644); err != nil {
-		return err
+	if err := os.WriteFile(archivedFile, []byte(fm.Stringify(r.archived)), 0o644); err != nil {
+		return nil, err
+	}
+	if err := propagate(r); err != nil {
+		return nil, err
 	}
 
-	// Propagate updated revisions_history to all existing archived versions
-	// This ensures every historical version page has the same, up-to-date list
-	entries, _ = os.ReadDir(revisionsDir)
-	for _, e := range entries {
-		name := e.Name()
-		var targetPath string
-		if isBundle {
-			if !e.IsDir() { // skip files in bundle .revisions root
-				continue
-			}
-			// bundle archived file is index.md inside version directory
-			targetPath = filepath.Join(revisionsDir, name, "index.md")
-		} else {
-			if e.IsDir() || !strings.HasSuffix(name, ".md") {
-				continue
-			}
-			targetPath = filepath.Join(revisionsDir, name)
+	// For bundles, copy all other files in the source bundle directory.
+	// Index files are left out: the other languages' index files are
+	// translations, archived when they are revised themselves.
+	if page.Branch {
+		if err := copyBranchResources(page.Path, archivedDir, resources); err != nil {
+			return nil, err
 		}
+	} else if page.Bundle {
+		if err := copyDirContents(page.Path, archivedDir, indexFiles(page.Path), resources); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := os.WriteFile(sourceFile, []byte(fm.Stringify(r.updated)), 0o644); err != nil {
+		return nil, err
+	}
 
-		// Skip if target file doesn't exist (defensive for bundles that may have assets only)
-		if _, err := os.Stat(targetPath); err != nil {
+	if page.Bundle && !dirExisted {
+		changes = append(changes, change{Source: page.Path, Target: archivedDir, Action: "copy"})
+	} else {
+		changes = append(changes, change{Source: sourceFile, Target: archivedFile, Action: "copy"})
+	}
+	changes = append(changes, change{Source: sourceFile, Target: sourceFile, Action: "write"})
+	return changes, nil
+}
+
+// propagated returns the archived versions of r's page with its history,
+// by path. The archive r replaces, if any, is left out.
+func propagated(r *revision) (map[string]string, error) {
+	out := map[string]string{}
+	for _, a := range r.page.Archives() {
+		// Skip bundle directories without an index file (assets only)
+		if a.File == "" || (!r.amend && a.Label == r.version) {
 			continue
 		}
-
-		// Read, update revisions_history, and write back
-		data, err := os.ReadFile(targetPath)
+		data, err := os.ReadFile(a.File)
 		if err != nil {
-			continue
+			return nil, err
 		}
-		fmParsed, err := fm.Parse(string(data))
+		parsed, err := fm.Parse(string(data))
 		if err != nil {
-			continue
+			return nil, fmt.Errorf("%s: %w", a.File, err)
+		}
+		if parsed, err = withHistory(parsed, r); err != nil {
+			return nil, fmt.Errorf("%s: %w", a.File, err)
 		}
-		// Migrate to list format
-		fmParsed, _ = fm.InjectList(fmParsed, "revisions_history", versions)
-		_ = os.WriteFile(targetPath, []byte(fm.Stringify(fmParsed)), 0o644)
+		out[a.File] = fm.Stringify(parsed)
 	}
+	return out, nil
+}
 
-	// For bundles, copy all other files in the source bundle directory
-	if isBundle {
-		srcDir := filepath.Dir(sourceFile)
-		if err := copyDirContents(srcDir, archivedDir, []string{"index.md"}); err != nil {
+// propagate writes the archives edited by propagated.
+func propagate(r *revision) error {
+	for path, s := range r.propagated {
+		if err := os.WriteFile(path, []byte(s), 0o644); err != nil {
 			return err
 		}
 	}
+	return nil
+}
 
-	// Update source file with current lastmod and date
-	now := time.Now()
-	currentDateTime := now.Format("2006-01-02T15:04:05-07:00")
-
-	// Update lastmod and date to current time (unquoted, RFC3339 format for Hugo compatibility)
-	parsed, _ = fm.InjectKVUnquoted(parsed, "lastmod", currentDateTime)
-	parsed, _ = fm.InjectKVUnquoted(parsed, "date", currentDateTime)
-	// Inject revisions_history into current page as list
-	parsed, _ = fm.InjectList(parsed, "revisions_history", versions)
+// versionFields pair what a version records about itself with the map in
+// which the values of all versions are kept by label, so that every version
+// can show the others'.
+var versionFields = []struct {
+	own, all string
+	listed   func(content.Version) string // the value as the manifest lists it
+}{
+	{"revision_note", "revisions_notes", func(v content.Version) string { return v.Note }},
+	{"revision_author", "revisions_authors", func(v content.Version) string { return v.Author }},
+}
 
-	if err := os.WriteFile(sourceFile, []byte(fm.Stringify(parsed)), 0o644); err != nil {
-		return err
+// withHistory writes the history of r into f: the version labels, the maps
+// of versionFields and the structured revisions list.
+func withHistory(f fm.FrontMatter, r *revision) (fm.FrontMatter, error) {
+	f, err := fm.InjectList(f, "revisions_history", r.versions)
+	if err != nil {
+		return f, err
+	}
+	// Org keywords cannot hold maps: an Org page keeps its keyword header
+	// and the maps are kept by its archives, which are YAML, and manifest
+	if f.Format == fm.Org {
+		return f, nil
+	}
+	for _, vf := range versionFields {
+		values := r.meta[vf.all]
+		if len(values) == 0 {
+			continue
+		}
+		m := make(map[string]any, len(values))
+		for l, v := range values {
+			m[l] = v
+		}
+		if f, err = fm.Set(f, vf.all, m); err != nil {
+			return f, err
+		}
 	}
+	if r.entries != nil {
+		return fm.Set(f, "revisions", r.entries)
+	}
+	return f, nil
+}
 
-	// Log operations
-	ops := lastOp{Timestamp: time.Now().Format(time.RFC3339)}
-	// Save original content for undo
-	ops.OriginalContent = string(b)
-	if isBundle {
-		ops.Changes = append(ops.Changes, change{Source: filepath.Dir(sourceFile), Target: archivedDir, Action: "copy"})
-	} else {
-		ops.Changes = append(ops.Changes, change{Source: sourceFile, Target: archivedFile, Action: "copy"})
+// metaFor returns the maps of versionFields for r's versions once revised:
+// the values recorded in the page so far, plus own for the new version. A
+// version the page has no value for, as on an Org page, which keeps no
+// maps, gets the manifest's, and the version being archived the one in its
+// own field.
+func metaFor(r *revision, own map[string]string) map[string]map[string]string {
+	manifest, _, _ := content.ReadManifest(r.page.ManifestPath())
+	meta := map[string]map[string]string{}
+	for _, vf := range versionFields {
+		values := map[string]string{}
+		recorded, _ := fm.GetMap(r.parsed, vf.all)
+		for _, l := range r.versions {
+			// Hugo and the Org editor may change the case of keys
+			for k, v := range recorded {
+				if strings.EqualFold(k, l) && v.String() != "" {
+					values[l] = v.String()
+				}
+			}
+			if v, ok := manifest.Find(l, r.page.Lang); ok && values[l] == "" && vf.listed(v) != "" {
+				values[l] = vf.listed(v)
+			}
+		}
+		if v, ok := fm.GetFold(r.parsed, vf.own); ok && values[r.version] == "" && v.String() != "" {
+			values[r.version] = v.String()
+		}
+		if own[vf.own] != "" {
+			values[r.latest] = own[vf.own]
+		}
+		meta[vf.all] = values
 	}
-	ops.Changes = append(ops.Changes, change{Source: sourceFile, Target: sourceFile, Action: "write"})
-	logPath := filepath.Join(config.LogDirectory, "last_op.json")
-	jb, _ := json.MarshalIndent(ops, "", "  ")
-	if err := os.WriteFile(logPath, jb, 0o644); err != nil {
-		return err
+	return meta
+}
+
+// indexFiles lists the index files, in every language, at the top of dir.
+func indexFiles(dir string) []string {
+	var out []string
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if !e.IsDir() && content.IsIndexFile(e.Name()) {
+			out = append(out, e.Name())
+		}
 	}
-	return nil
+	return out
 }
 
-// copyDirContents copies all files and subdirectories from src to dst.
-// excludeFiles lists relative file names in src to skip (e.g., "index.md").
-func copyDirContents(src, dst string, excludeFiles []string) error {
+// copyDirContents copies all files and subdirectories from src to dst
+// with c. excludeFiles lists relative file names in src to skip (e.g.,
+// "index.md").
+func copyDirContents(src, dst string, excludeFiles []string, c resourceCopier) error {
 	// Build exclusion set
 	exclude := map[string]struct{}{}
 	for _, f := range excludeFiles {
@@ -288,58 +634,48 @@ func copyDirContents(src, dst string, excludeFiles []string) error {
 		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
 			return err
 		}
-		// Copy file bytes
-		data, err := os.ReadFile(path)
-		if err != nil {
-			return err
-		}
-		return os.WriteFile(target, data, 0o644)
+		return c.copyFile(path, target, rel)
 	})
 }
 
-// extractDocumentDate extracts the publish date to form version (prefers date, then lastmod)
-func extractDocumentDate(frontMatter fm.FrontMatter, format string) string {
-	// Prefer date field (publish date)
-	date := fm.GetValue(frontMatter, "date")
-	if date != "" {
-		if t, err := parseDate(date); err == nil {
-			return t.Format(format)
-		}
+// copyBranchResources copies a branch bundle's own resources into dst. As in
+// Hugo, those are the non-content files next to _index.<ext>; content files
+// are child pages and subdirectories hold child sections and bundles, so
+// neither belongs in the archive. Files are copied with c.
+func copyBranchResources(src, dst string, c resourceCopier) error {
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
 	}
-
-	// Fallback to lastmod
-	lastmod := fm.GetValue(frontMatter, "lastmod")
-	if lastmod != "" {
-		if t, err := parseDate(lastmod); err == nil {
-			return t.Format(format)
+	for _, e := range entries {
+		if e.IsDir() || content.IsContentFile(e.Name()) {
+			continue
+		}
+		if err := c.copyFile(filepath.Join(src, e.Name()), filepath.Join(dst, e.Name()), e.Name()); err != nil {
+			return err
 		}
 	}
-
-	// If no date found or parse failed, use current time
-	return time.Now().Format(format)
+	return nil
 }
 
-// parseDate tries to parse date string in common formats
-func parseDate(dateStr string) (time.Time, error) {
-	formats := []string{
-		time.RFC3339,
-		"2006-01-02T15:04:05Z07:00",
-		"2006-01-02 15:04:05 -0700",
-		"2006-01-02T15:04:05",
-		"2006-01-02 15:04:05",
-		"2006-01-02",
+// extractDocumentLabel returns the label of the version being archived. A
+// page revised before keeps the label it was given then, the newest entry
+// of its revisions_history; otherwise the label is made from the page date,
+// resolved with the site's [frontmatter] date handlers as Hugo would, in
+// the zone of now.
+func extractDocumentLabel(siteCfg site.Config, strategy label.Strategy, sourceFile string, frontMatter fm.FrontMatter, fields map[string]any, now time.Time) (string, error) {
+	if history := fm.GetList(frontMatter, "revisions_history"); len(history) > 0 {
+		return label.Latest(strategy, history), nil
 	}
-
-	for _, format := range formats {
-		if t, err := time.Parse(format, dateStr); err == nil {
-			return t, nil
-		}
+	date, ok := resolveDate(siteCfg.FrontMatter.Date, sourceFile, frontMatter, now.Location())
+	if !ok {
+		// If no date found or parse failed, use the time of the revision
+		date = now
 	}
-
-	return time.Time{}, fmt.Errorf("unable to parse date: %s", dateStr)
+	return strategy.First(label.Context{Date: date, Counter: 1, Page: fields})
 }
 
-func extractBaseURL(f fm.FrontMatter, bundleDir string) string {
+func extractBaseURL(f fm.FrontMatter, page content.Page, siteCfg site.Config) string {
 	// First check if url field exists in front matter
 	existingURL := fm.GetValue(f, "url")
 	if existingURL != "" {
@@ -351,47 +687,48 @@ func extractBaseURL(f fm.FrontMatter, bundleDir string) string {
 	}
 
 	// Try to get URL from Hugo permalink rules (respects Hugo config)
-	if hugoURL, err := getPageURLFromHugo(bundleDir, f); err == nil && hugoURL != "" {
+	if hugoURL, err := getPageURLFromHugo(siteCfg, page.Source, f); err == nil && hugoURL != "" {
 		if !strings.HasSuffix(hugoURL, "/") {
 			hugoURL += "/"
 		}
 		return hugoURL
 	}
 
+	// Path of the page in Hugo's content tree, e.g. content/posts/my-post -> posts/my-post
+	logical, root, _ := siteCfg.LogicalPath(page.Path)
+
+	// Pages outside the default language live under their language prefix;
+	// a language's own content directory sets the language of its pages
+	lang := page.Lang
+	if lang == "" {
+		lang = root.Lang
+	}
+	langPrefix := siteCfg.LanguagePrefix(lang)
+
 	// Check for slug field
 	slug := fm.GetValue(f, "slug")
 	if slug != "" {
 		// Derive from slug (assume section from path)
-		section := extractSection(bundleDir)
+		section := extractSection(logical)
 		if section != "" {
-			return fmt.Sprintf("/%s/%s/", section, slug)
+			return fmt.Sprintf("%s/%s/%s/", langPrefix, section, slug)
 		}
-		return fmt.Sprintf("/%s/", slug)
+		return fmt.Sprintf("%s/%s/", langPrefix, slug)
 	}
 
-	// Fallback: derive from bundle directory path
+	// Fallback: derive from the logical path
 	// e.g., content/posts/my-post -> /posts/my-post/
-	parts := strings.Split(bundleDir, string(filepath.Separator))
-	var afterContent []string
-	found := false
-	for _, p := range parts {
-		if found {
-			afterContent = append(afterContent, p)
-		}
-		if p == "content" {
-			found = true
-		}
+	if logical == "" {
+		return langPrefix + "/"
 	}
-	return "/" + strings.Join(afterContent, "/") + "/"
+	return langPrefix + "/" + logical + "/"
 }
 
-func extractSection(bundleDir string) string {
-	// Extract section from path like content/posts/demo -> posts
-	parts := strings.Split(bundleDir, string(filepath.Separator))
-	for i, p := range parts {
-		if p == "content" && i+1 < len(parts) {
-			return parts[i+1]
-		}
+// extractSection returns the top-level section of a logical path, e.g.
+// posts/demo -> posts
+func extractSection(logical string) string {
+	if i := strings.Index(logical, "/"); i > 0 {
+		return logical[:i]
 	}
 	return ""
 }
@@ -0,0 +1,75 @@
+package revise
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ifeitao/hugo-revise/internal/content"
+	"github.com/ifeitao/hugo-revise/internal/site"
+)
+
+// hiddenSection is the _index written into revisions directories. It makes
+// the directory a section that Hugo neither renders nor lists, in
+// .Site.Sections or anywhere else, and whose resources, the manifest among
+// them, it does not publish. The archives in it are still rendered at
+// their own URLs.
+const hiddenSection = "---\nbuild:\n  list: never\n  publishResources: false\n  render: never\n---\n"
+
+// hiddenIndexes returns the _index files that keep the revisions of page
+// out of the published site: one in its revisions directory, so that the
+// manifest there is not published, and one in the directory at the top of
+// the content tree holding it when that is not the page's own section.
+// Hugo makes a section of every top-level directory, so the revisions of
+// content/about.md or of the branch bundle content/docs/ in the sibling
+// layout, and the revisions directory of the central and mount layouts,
+// would be sections of their own.
+func hiddenIndexes(siteCfg site.Config, page content.Page) []string {
+	name := "_index.md"
+	if page.Lang != "" {
+		name = "_index." + page.Lang + ".md"
+	}
+	dir := page.RevisionsDir()
+	out := []string{filepath.Join(dir, name)}
+	logical, _, ok := siteCfg.LogicalPath(dir)
+	if !ok || logical == "" {
+		return out
+	}
+	top := dir
+	for {
+		l, _, ok := siteCfg.LogicalPath(filepath.Dir(top))
+		if !ok || l == "" {
+			break
+		}
+		top = filepath.Dir(top)
+	}
+	if top == dir {
+		return out
+	}
+	section, _, _ := strings.Cut(logical, "/")
+	if own, _, ok := siteCfg.LogicalPath(page.Path); ok {
+		if first, rest, _ := strings.Cut(own, "/"); first == section && rest != "" {
+			return out
+		}
+	}
+	return append(out, filepath.Join(top, name))
+}
+
+// hideRevisions writes those of the _index files indexes, of the revisions
+// of the page at source, that do not exist yet and returns the changes made.
+func hideRevisions(indexes []string, source string) ([]change, error) {
+	var changes []change
+	for _, index := range indexes {
+		if exists(index) {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(index), 0o755); err != nil {
+			return changes, err
+		}
+		if err := os.WriteFile(index, []byte(hiddenSection), 0o644); err != nil {
+			return changes, err
+		}
+		changes = append(changes, change{Source: source, Target: index, Action: "copy"})
+	}
+	return changes, nil
+}
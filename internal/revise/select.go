@@ -0,0 +1,171 @@
+package revise
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ifeitao/hugo-revise/internal/content"
+	"github.com/ifeitao/hugo-revise/internal/site"
+)
+
+// locate turns the page selection on the command line into a path that
+// content.Resolve understands. A page is selected by its public URL
+// (opts.URL), its title (opts.Title), a path on disk, or its path in the
+// content tree ("posts/my-post"), which is looked up under the site's
+// content roots and then in hugo list all.
+func locate(siteCfg site.Config, pathPrefix string, opts Options) (string, error) {
+	if pathPrefix != "" && (opts.URL != "" || opts.Title != "") {
+		return "", fmt.Errorf("give either a path or --url/--title, not both")
+	}
+	switch {
+	case opts.URL != "":
+		// Exact first; a suffix match covers a baseURL with a path of its own
+		want := normalizeURL(opts.URL)
+		return pickBest(siteCfg, fmt.Sprintf("--url %s", opts.URL), func(p hugoPage) bool {
+			return normalizeURL(p.Permalink) == want
+		}, func(p hugoPage) bool {
+			return want != "/" && strings.HasSuffix(normalizeURL(p.Permalink), want)
+		})
+	case opts.Title != "":
+		// Exact (ignoring case) first, then titles containing the text
+		want := strings.ToLower(strings.TrimSpace(opts.Title))
+		return pickBest(siteCfg, fmt.Sprintf("--title %q", opts.Title), func(p hugoPage) bool {
+			return strings.ToLower(strings.TrimSpace(p.Title)) == want
+		}, func(p hugoPage) bool {
+			return strings.Contains(strings.ToLower(p.Title), want)
+		})
+	case pathPrefix == "":
+		return "", fmt.Errorf("no page given: pass a path, --url or --title")
+	}
+
+	if _, err := content.Resolve(pathPrefix, siteCfg.LanguageCodes()); err == nil || exists(pathPrefix) {
+		return pathPrefix, nil
+	}
+
+	// A path in the content tree, under one of the content roots
+	logical := strings.Trim(filepath.ToSlash(filepath.Clean(pathPrefix)), "/")
+	var found []string
+	for _, r := range siteCfg.ContentRoots {
+		rest := logical
+		if r.Target != "" {
+			if logical != r.Target && !strings.HasPrefix(logical, r.Target+"/") {
+				continue
+			}
+			rest = strings.TrimPrefix(strings.TrimPrefix(logical, r.Target), "/")
+		}
+		candidate := filepath.Join(r.Dir, filepath.FromSlash(rest))
+		if page, err := content.Resolve(candidate, siteCfg.LanguageCodes()); err == nil {
+			found = append(found, page.Source)
+		}
+	}
+	switch len(found) {
+	case 1:
+		return found[0], nil
+	case 0:
+	default:
+		return "", fmt.Errorf("%s matches several pages:\n  %s", pathPrefix, strings.Join(found, "\n  "))
+	}
+
+	// Finally, the path column of hugo list all
+	if siteCfg.Root != "" {
+		matches, err := pickAll(siteCfg, func(p hugoPage) bool {
+			base := strings.TrimSuffix(p.Path, filepath.Ext(p.Path))
+			return base == logical || strings.HasSuffix(base, "/"+logical) ||
+				strings.TrimSuffix(base, "/index") == logical || strings.TrimSuffix(base, "/_index") == logical
+		})
+		if err == nil && len(matches) > 0 {
+			return one(siteCfg, pathPrefix, matches)
+		}
+	}
+	return pathPrefix, nil
+}
+
+// pickBest returns the source file of the one page of hugo list all that
+// matches exactly, or failing that loosely.
+func pickBest(siteCfg site.Config, what string, exact, loose func(hugoPage) bool) (string, error) {
+	matches, err := pickAll(siteCfg, exact)
+	if err != nil {
+		return "", err
+	}
+	if len(matches) == 0 {
+		if matches, err = pickAll(siteCfg, loose); err != nil {
+			return "", err
+		}
+	}
+	return one(siteCfg, what, matches)
+}
+
+func pickAll(siteCfg site.Config, match func(hugoPage) bool) ([]hugoPage, error) {
+	pages, err := listHugoPages(siteCfg)
+	if err != nil {
+		return nil, err
+	}
+	var matches []hugoPage
+	for _, p := range pages {
+		if match(p) {
+			matches = append(matches, p)
+		}
+	}
+	return matches, nil
+}
+
+// one returns the source file of the single match, or an error listing
+// the candidates.
+func one(siteCfg site.Config, what string, matches []hugoPage) (string, error) {
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no page matches %s", what)
+	case 1:
+		return sourceOf(siteCfg, matches[0])
+	}
+	var lines []string
+	for _, m := range matches {
+		line := m.Path + "  " + permalinkPath(m.Permalink)
+		if m.Title != "" {
+			line += fmt.Sprintf("  %q", m.Title)
+		}
+		lines = append(lines, line)
+	}
+	return "", fmt.Errorf("%d pages match %s; pick one by path:\n  %s", len(matches), what, strings.Join(lines, "\n  "))
+}
+
+// sourceOf finds the content file of a hugo list all row, whose path is
+// either relative to the project root or a path in the content tree.
+func sourceOf(siteCfg site.Config, p hugoPage) (string, error) {
+	if onDisk := filepath.Join(siteCfg.Root, filepath.FromSlash(p.Path)); exists(onDisk) {
+		return onDisk, nil
+	}
+	for _, r := range siteCfg.ContentRoots {
+		rest := p.Path
+		if r.Target != "" {
+			if !strings.HasPrefix(p.Path, r.Target+"/") {
+				continue
+			}
+			rest = strings.TrimPrefix(p.Path, r.Target+"/")
+		}
+		if candidate := filepath.Join(r.Dir, filepath.FromSlash(rest)); exists(candidate) {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("content file %s not found", p.Path)
+}
+
+// normalizeURL reduces a URL to its path with leading and trailing slashes.
+func normalizeURL(u string) string {
+	u = permalinkPath(strings.TrimSpace(u))
+	if i := strings.IndexAny(u, "?#"); i >= 0 {
+		u = u[:i]
+	}
+	u = "/" + strings.Trim(u, "/") + "/"
+	if u == "//" {
+		u = "/"
+	}
+	return u
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
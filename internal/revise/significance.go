@@ -0,0 +1,223 @@
+package revise
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/ifeitao/hugo-revise/internal/config"
+	"github.com/ifeitao/hugo-revise/internal/content"
+	"github.com/ifeitao/hugo-revise/internal/fm"
+	"github.com/ifeitao/hugo-revise/internal/label"
+)
+
+// What to do when the version being archived hardly differs from the
+// latest archive.
+const (
+	minorChangeWarn  = "warn"  // archive it and say so
+	minorChangeError = "error" // refuse the revision unless forced
+)
+
+// changeReport compares the body of the version being archived with the
+// latest archive.
+type changeReport struct {
+	against         string   // label of the archive compared with
+	addedWords      int      // words added, counted as a bag
+	removedWords    int      // words removed
+	changedPercent  float64  // lines added and removed, per cent of the lines of both
+	addedHeadings   []string // headings only in the new body
+	removedHeadings []string // headings only in the old body
+}
+
+func (c changeReport) String() string {
+	s := fmt.Sprintf("+%d/-%d words, %.1f%% of lines changed since %s", c.addedWords, c.removedWords, c.changedPercent, c.against)
+	var headings []string
+	for _, h := range c.addedHeadings {
+		headings = append(headings, "+"+h)
+	}
+	for _, h := range c.removedHeadings {
+		headings = append(headings, "-"+h)
+	}
+	if len(headings) > 0 {
+		s += ", headings: " + strings.Join(headings, ", ")
+	}
+	return s
+}
+
+// identical reports whether the bodies are the same, ignoring blank lines
+// and trailing whitespace.
+func (c changeReport) identical() bool {
+	return c.addedWords == 0 && c.removedWords == 0 && c.changedPercent == 0
+}
+
+// minorChangeMode returns versioning.on_minor_change, checked.
+func minorChangeMode(cfg config.Config) (string, error) {
+	switch mode := cfg.Versioning.OnMinorChange; mode {
+	case "":
+		return minorChangeWarn, nil
+	case minorChangeWarn, minorChangeError:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown on_minor_change %q (want warn or error)", mode)
+	}
+}
+
+// checkSignificance compares the version r archives with the latest
+// archive before it. An identical body is refused and one changed less than
+// versioning.min_change per cent is warned about or refused, as
+// versioning.on_minor_change says, unless force is set. The report is
+// written to standard error.
+func checkSignificance(cfg config.Config, strategy label.Strategy, mode string, r *revision, force bool) error {
+	if r.amend {
+		return nil
+	}
+	latest, ok := latestArchive(strategy, r)
+	if !ok {
+		return nil
+	}
+	b, err := os.ReadFile(latest.File)
+	if err != nil {
+		return err
+	}
+	old, err := fm.Parse(string(b))
+	if err != nil {
+		return fmt.Errorf("%s: %w", latest.File, err)
+	}
+	report := compareBodies(old.Content, r.parsed.Content, r.page.Ext)
+	report.against = latest.Label
+	fmt.Fprintf(os.Stderr, "%s: %s\n", r.page.Source, report)
+
+	switch {
+	case force:
+		return nil
+	case report.identical():
+		return fmt.Errorf("%s is the same as its archive %s; edit it first or pass --force", r.page.Source, latest.Label)
+	case report.changedPercent >= cfg.Versioning.MinChange:
+		return nil
+	case mode == minorChangeError:
+		return fmt.Errorf("%s changed %.1f%% of its lines since %s, less than min_change %g%%; pass --force to archive it anyway", r.page.Source, report.changedPercent, latest.Label, cfg.Versioning.MinChange)
+	}
+	fmt.Fprintf(os.Stderr, "warning: %s changed less than min_change %g%% since %s\n", r.page.Source, cfg.Versioning.MinChange, latest.Label)
+	return nil
+}
+
+// latestArchive returns the newest archive of r's page by the strategy's
+// order, other than the one r replaces. Archives without an index file are
+// left out.
+func latestArchive(strategy label.Strategy, r *revision) (content.Archive, bool) {
+	var latest content.Archive
+	found := false
+	for _, a := range r.page.Archives() {
+		if a.File == "" || a.Label == r.version {
+			continue
+		}
+		if !found || strategy.Less(latest.Label, a.Label) {
+			latest, found = a, true
+		}
+	}
+	return latest, found
+}
+
+// compareBodies diffs two page bodies line by line, ignoring blank lines
+// and trailing whitespace. ext is the extension of the content files.
+func compareBodies(oldBody, newBody, ext string) changeReport {
+	oldLines, newLines := bodyLines(oldBody), bodyLines(newBody)
+	var c changeReport
+	if total := len(oldLines) + len(newLines); total > 0 {
+		common := lcsLength(oldLines, newLines)
+		changed := len(oldLines) + len(newLines) - 2*common
+		c.changedPercent = 100 * float64(changed) / float64(total)
+	}
+
+	words := map[string]int{}
+	for _, w := range strings.Fields(oldBody) {
+		words[w]--
+	}
+	for _, w := range strings.Fields(newBody) {
+		words[w]++
+	}
+	for _, n := range words {
+		if n > 0 {
+			c.addedWords += n
+		} else {
+			c.removedWords -= n
+		}
+	}
+
+	oldHeadings, newHeadings := headings(oldLines, ext), headings(newLines, ext)
+	c.addedHeadings = missing(newHeadings, oldHeadings)
+	c.removedHeadings = missing(oldHeadings, newHeadings)
+	return c
+}
+
+// missing returns the headings of a that b lacks, in order.
+func missing(a, b []string) []string {
+	var out []string
+	for _, h := range a {
+		if !slices.Contains(b, h) && !slices.Contains(out, h) {
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+// bodyLines returns the non-blank lines of body without trailing
+// whitespace.
+func bodyLines(body string) []string {
+	var lines []string
+	for _, l := range strings.Split(body, "\n") {
+		if l = strings.TrimRight(l, " \t\r"); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
+
+// Headings of the content formats: AsciiDoc section titles, Org headlines
+// and, for Markdown and the rest, ATX headings.
+var (
+	adocHeadingRe     = regexp.MustCompile(`^={1,6}\s+(.+)$`)
+	orgHeadingRe      = regexp.MustCompile(`^\*+\s+(.+)$`)
+	markdownHeadingRe = regexp.MustCompile(`^#{1,6}\s+(.+?)(?:\s+#+)?$`)
+)
+
+// headings returns the text of the headings among lines of a file with
+// extension ext.
+func headings(lines []string, ext string) []string {
+	re := markdownHeadingRe
+	switch strings.ToLower(ext) {
+	case ".adoc", ".asciidoc", ".ad":
+		re = adocHeadingRe
+	case ".org":
+		re = orgHeadingRe
+	}
+	var out []string
+	for _, l := range lines {
+		if m := re.FindStringSubmatch(l); m != nil {
+			out = append(out, m[1])
+		}
+	}
+	return out
+}
+
+// lcsLength returns the length of the longest common subsequence of a and
+// b, keeping one row of the table.
+func lcsLength(a, b []string) int {
+	row := make([]int, len(b)+1)
+	for i := range a {
+		diag := 0
+		for j := range b {
+			up := row[j+1]
+			switch {
+			case a[i] == b[j]:
+				row[j+1] = diag + 1
+			case row[j] > up:
+				row[j+1] = row[j]
+			}
+			diag = up
+		}
+	}
+	return row[len(b)]
+}
@@ -0,0 +1,102 @@
+package revise
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCompareBodies(t *testing.T) {
+	tests := []struct {
+		name            string
+		oldBody         string
+		newBody         string
+		ext             string
+		added, removed  int
+		changedPercent  float64
+		addedHeadings   []string
+		removedHeadings []string
+	}{
+		{
+			name:    "identical",
+			oldBody: "one two\nthree\n",
+			newBody: "one two\nthree\n",
+			ext:     ".md",
+		},
+		{
+			name:    "blank lines and trailing spaces are ignored",
+			oldBody: "one two\n\nthree\n",
+			newBody: "one two  \nthree\r\n\n\n",
+			ext:     ".md",
+		},
+		{
+			name:           "line added",
+			oldBody:        "a\nb\nc\n",
+			newBody:        "a\nb\nc\nd e\n",
+			ext:            ".md",
+			added:          2,
+			changedPercent: 100.0 / 7,
+		},
+		{
+			name:           "line replaced",
+			oldBody:        "a\nb\n",
+			newBody:        "a\nc\n",
+			ext:            ".md",
+			added:          1,
+			removed:        1,
+			changedPercent: 50,
+		},
+		{
+			name:           "moved words count as lines only",
+			oldBody:        "a b\n",
+			newBody:        "b a\n",
+			ext:            ".md",
+			changedPercent: 100,
+		},
+		{
+			name:            "markdown headings",
+			oldBody:         "# Intro\ntext\n## Old ##\n",
+			newBody:         "# Intro\ntext\n## New\n",
+			ext:             ".md",
+			added:           1,
+			removed:         2,
+			changedPercent:  100.0 / 3,
+			addedHeadings:   []string{"New"},
+			removedHeadings: []string{"Old"},
+		},
+		{
+			name:           "org headlines",
+			oldBody:        "* Intro\n",
+			newBody:        "* Intro\n** Details\n",
+			ext:            ".org",
+			added:          2,
+			changedPercent: 100.0 / 3,
+			addedHeadings:  []string{"Details"},
+		},
+		{
+			name:           "asciidoc section titles",
+			oldBody:        "== Intro\n",
+			newBody:        "== Intro\n=== Details\n",
+			ext:            ".ad",
+			added:          2,
+			changedPercent: 100.0 / 3,
+			addedHeadings:  []string{"Details"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := compareBodies(tt.oldBody, tt.newBody, tt.ext)
+			if c.addedWords != tt.added || c.removedWords != tt.removed {
+				t.Errorf("words = +%d/-%d, want +%d/-%d", c.addedWords, c.removedWords, tt.added, tt.removed)
+			}
+			if diff := c.changedPercent - tt.changedPercent; diff > 1e-9 || diff < -1e-9 {
+				t.Errorf("changedPercent = %v, want %v", c.changedPercent, tt.changedPercent)
+			}
+			if !slices.Equal(c.addedHeadings, tt.addedHeadings) || !slices.Equal(c.removedHeadings, tt.removedHeadings) {
+				t.Errorf("headings = +%v -%v, want +%v -%v", c.addedHeadings, c.removedHeadings, tt.addedHeadings, tt.removedHeadings)
+			}
+			if got, want := c.identical(), tt.changedPercent == 0 && tt.added == 0 && tt.removed == 0; got != want {
+				t.Errorf("identical = %v, want %v", got, want)
+			}
+		})
+	}
+}
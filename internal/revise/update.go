@@ -0,0 +1,131 @@
+package revise
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ifeitao/hugo-revise/internal/clock"
+	"github.com/ifeitao/hugo-revise/internal/config"
+	"github.com/ifeitao/hugo-revise/internal/content"
+	"github.com/ifeitao/hugo-revise/internal/fm"
+	"github.com/ifeitao/hugo-revise/internal/site"
+)
+
+// Kinds of minor updates, recorded in the page's changelog without
+// archiving a version.
+const (
+	updateCorrection    = "correction"    // something was wrong and has been fixed
+	updateClarification = "clarification" // something was unclear and has been reworded
+	updateUpdate        = "update"        // something new has been added
+)
+
+// UpdateOptions are the command-line choices for a minor update.
+type UpdateOptions struct {
+	URL     string // select the page by its public URL instead of a path
+	Title   string // select the page by its title instead of a path
+	Kind    string // correction, clarification or update
+	Message string // what changed
+	Author  string // who made the update, instead of HUGO_REVISE_AUTHOR or git's user
+}
+
+// Update records a minor change to the current version of the page at
+// pathPrefix: lastmod is refreshed and an entry is added to the changelog
+// list in its front matter, oldest first:
+//
+//	changelog:
+//	  - author: Jane Doe
+//	    date: "2025-12-03T09:30:00+08:00"
+//	    kind: correction
+//	    note: fixed the default port
+//
+// Nothing is archived. The changelog is copied into the archive with the
+// rest of the front matter when the page is revised.
+func Update(cfg config.Config, pathPrefix string, opts UpdateOptions) error {
+	if err := config.EnsureLogDir(); err != nil {
+		return err
+	}
+	kind := opts.Kind
+	switch kind {
+	case "":
+		kind = updateUpdate
+	case updateCorrection, updateClarification, updateUpdate:
+	default:
+		return fmt.Errorf("unknown update kind %q (want correction, clarification or update)", kind)
+	}
+	if opts.Message == "" {
+		return fmt.Errorf("an update needs a note saying what changed: pass -m")
+	}
+
+	siteCfg, err := site.Discover(filepath.Dir(filepath.Clean(pathPrefix)))
+	if err != nil {
+		return err
+	}
+	pathPrefix, err = locate(siteCfg, pathPrefix, Options{URL: opts.URL, Title: opts.Title})
+	if err != nil {
+		return err
+	}
+	page, err := content.Resolve(pathPrefix, siteCfg.LanguageCodes())
+	if err != nil {
+		return err
+	}
+	b, err := os.ReadFile(page.Source)
+	if err != nil {
+		return fmt.Errorf("read source file: %w", err)
+	}
+	parsed, err := fm.Parse(string(b))
+	if err != nil {
+		return err
+	}
+
+	loc, err := clock.Location(cfg.Versioning.Timezone, siteCfg.TimeZone)
+	if err != nil {
+		return err
+	}
+	now, err := clock.Now(loc)
+	if err != nil {
+		return err
+	}
+	author, contact := resolveAuthor(opts.Author, siteCfg.Root)
+
+	entry := map[string]any{"date": now.Format(dateLayout), "kind": kind, "note": opts.Message}
+	if author != "" {
+		entry["author"] = author
+	}
+	var changelog []any
+	if v, ok := fm.Get(parsed, "changelog"); ok {
+		if v.Kind != fm.ListKind {
+			return fmt.Errorf("%s: changelog is not a list", page.Source)
+		}
+		for _, e := range v.List() {
+			changelog = append(changelog, e.Interface())
+		}
+	}
+	if parsed, err = fm.InjectKVUnquoted(parsed, "lastmod", now.Format(dateLayout)); err != nil {
+		return fmt.Errorf("%s: %w", page.Source, err)
+	}
+	if parsed, err = fm.Set(parsed, "changelog", append(changelog, entry)); err != nil {
+		return fmt.Errorf("%s: %w", page.Source, err)
+	}
+	if err := os.WriteFile(page.Source, []byte(fm.Stringify(parsed)), 0o644); err != nil {
+		return err
+	}
+
+	ops := lastOp{
+		Timestamp: now.Format(time.RFC3339),
+		Command:   "update",
+		Message:   opts.Message,
+		Author:    contact,
+		Originals: map[string]string{page.Source: string(b)},
+		Changes:   []change{{Source: page.Source, Target: page.Source, Action: "write"}},
+	}
+	// Archives replaced by the previous revision can no longer be restored
+	if err := os.RemoveAll(overwrittenDir); err != nil {
+		return err
+	}
+	logPath := filepath.Join(config.LogDirectory, "last_op.json")
+	jb, _ := json.MarshalIndent(ops, "", "  ")
+	return os.WriteFile(logPath, jb, 0o644)
+}
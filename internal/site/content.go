@@ -0,0 +1,100 @@
+package site
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ContentRoot is a directory Hugo mounts into the content tree.
+type ContentRoot struct {
+	Dir    string // absolute directory on disk
+	Target string // where it is mounted in the content tree, "" for the top
+	Lang   string // language of the files in it, "" when not fixed
+}
+
+// contentRoots works out the content mounts the way Hugo does: content
+// mounts in [module] replace the default ones; otherwise each language with
+// its own contentDir gets that directory and the rest share contentDir.
+func contentRoots(root, contentDir string, langs []Language, mounts any) []ContentRoot {
+	abs := func(dir string) string {
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(root, dir)
+		}
+		if a, err := filepath.Abs(dir); err == nil {
+			return a
+		}
+		return dir
+	}
+
+	var out []ContentRoot
+	list, _ := mounts.([]any)
+	for _, m := range list {
+		mount, ok := m.(map[string]any)
+		if !ok {
+			continue
+		}
+		source := fmt.Sprint(mount["source"])
+		target := filepath.ToSlash(filepath.Clean(fmt.Sprint(mount["target"])))
+		if target != "content" && !strings.HasPrefix(target, "content/") {
+			continue
+		}
+		lang := ""
+		if l, ok := mount["lang"].(string); ok {
+			lang = strings.ToLower(l)
+		}
+		out = append(out, ContentRoot{
+			Dir:    abs(source),
+			Target: strings.TrimPrefix(strings.TrimPrefix(target, "content"), "/"),
+			Lang:   lang,
+		})
+	}
+	if len(out) == 0 {
+		shared := false
+		for _, l := range langs {
+			if l.ContentDir == "" {
+				shared = true
+				continue
+			}
+			out = append(out, ContentRoot{Dir: abs(l.ContentDir), Lang: l.Code})
+		}
+		if shared {
+			out = append(out, ContentRoot{Dir: abs(contentDir)})
+		}
+	}
+	// Most specific directory first, so nested mounts win.
+	sort.SliceStable(out, func(i, j int) bool { return len(out[i].Dir) > len(out[j].Dir) })
+	return out
+}
+
+// LogicalPath maps a path on disk to its path in Hugo's content tree
+// ("posts/my-post"), along with the content root it belongs to. Outside a
+// Hugo site the path after a "content" directory is used.
+func (c Config) LogicalPath(path string) (string, ContentRoot, bool) {
+	if len(c.ContentRoots) == 0 {
+		parts := strings.Split(filepath.ToSlash(filepath.Clean(path)), "/")
+		for i, p := range parts {
+			if p == "content" {
+				return strings.Join(parts[i+1:], "/"), ContentRoot{}, true
+			}
+		}
+		return "", ContentRoot{}, false
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", ContentRoot{}, false
+	}
+	for _, r := range c.ContentRoots {
+		rel, err := filepath.Rel(r.Dir, abs)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		logical := filepath.ToSlash(filepath.Join(r.Target, rel))
+		if logical == "." {
+			logical = ""
+		}
+		return logical, r, true
+	}
+	return "", ContentRoot{}, false
+}
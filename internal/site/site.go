@@ -0,0 +1,268 @@
+// Package site reads the parts of a Hugo site's configuration that
+// hugo-revise needs to mirror Hugo's own behaviour.
+package site
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// configNames are the root configuration files Hugo looks for, in order.
+var configNames = []string{"hugo.toml", "hugo.yaml", "hugo.yml", "hugo.json", "config.toml", "config.yaml", "config.yml", "config.json"}
+
+// Config is the subset of the Hugo configuration used by hugo-revise.
+type Config struct {
+	Root        string
+	FrontMatter FrontMatter
+	TimeZone    string // the site's timeZone, in which Hugo reads dates without an offset
+
+	DefaultLanguage         string
+	DefaultLanguageInSubdir bool
+	Languages               []Language // default language first, then by weight
+
+	ContentRoots []ContentRoot
+}
+
+// Language is one entry of the site's [languages] table.
+type Language struct {
+	Code       string
+	ContentDir string // the language's own contentDir, relative to Root; "" when shared
+}
+
+// LanguageCodes returns the codes of the site's languages, default first.
+func (c Config) LanguageCodes() []string {
+	codes := make([]string, len(c.Languages))
+	for i, l := range c.Languages {
+		codes[i] = l.Code
+	}
+	return codes
+}
+
+// LanguagePrefix is the URL prefix Hugo gives pages in lang ("/zh"), or ""
+// for the default language unless it is served from its own subdirectory.
+// An empty lang means the default language.
+func (c Config) LanguagePrefix(lang string) string {
+	if lang == "" {
+		lang = c.DefaultLanguage
+	}
+	if strings.EqualFold(lang, c.DefaultLanguage) && !c.DefaultLanguageInSubdir {
+		return ""
+	}
+	return "/" + strings.ToLower(lang)
+}
+
+// FrontMatter mirrors Hugo's [frontmatter] section: for each page date, the
+// ordered list of front matter fields and handlers (":filename",
+// ":fileModTime", ":git", ":default") that may provide it.
+type FrontMatter struct {
+	Date        []string
+	Lastmod     []string
+	PublishDate []string
+	ExpiryDate  []string
+}
+
+// Hugo's defaults for the [frontmatter] section.
+var (
+	DefaultDate        = []string{"date", "publishdate", "pubdate", "published", "lastmod", "modified"}
+	DefaultLastmod     = []string{":git", "lastmod", "modified", "date", "publishdate", "pubdate", "published"}
+	DefaultPublishDate = []string{"publishdate", "pubdate", "published", "date"}
+	DefaultExpiryDate  = []string{"expirydate", "unpublishdate"}
+)
+
+// dateAliases are the extra field names Hugo accepts for each date field.
+var dateAliases = map[string][]string{
+	"lastmod":     {"modified"},
+	"publishdate": {"pubdate", "published"},
+	"expirydate":  {"unpublishdate"},
+}
+
+// FindRoot walks up from startPath to the directory holding the Hugo
+// configuration.
+func FindRoot(startPath string) (string, error) {
+	dir := startPath
+	for {
+		// Check for Hugo config files
+		for _, name := range configNames {
+			if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+				return dir, nil
+			}
+		}
+		if fi, err := os.Stat(filepath.Join(dir, "config", "_default")); err == nil && fi.IsDir() {
+			return dir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return "", fmt.Errorf("Hugo project root not found")
+}
+
+// Default is the configuration Hugo assumes when a site sets nothing.
+func Default() Config {
+	return Config{
+		FrontMatter: FrontMatter{
+			Date:        DefaultDate,
+			Lastmod:     DefaultLastmod,
+			PublishDate: DefaultPublishDate,
+			ExpiryDate:  DefaultExpiryDate,
+		},
+		DefaultLanguage: "en",
+		Languages:       []Language{{Code: "en"}},
+	}
+}
+
+// Discover loads the configuration of the site containing path, or of the
+// site in the working directory for content mounted from outside the site.
+// It returns Default when neither is inside a Hugo site.
+func Discover(path string) (Config, error) {
+	root, err := FindRoot(path)
+	if err != nil {
+		if root, err = FindRoot("."); err != nil {
+			return Default(), nil
+		}
+	}
+	return Load(root)
+}
+
+// Load reads the site configuration at root: the root config file and the
+// config/_default directory, where a file named after a top-level key
+// (frontmatter.toml, say) holds that key's section.
+func Load(root string) (Config, error) {
+	v := viper.New()
+	for _, name := range configNames {
+		path := filepath.Join(root, name)
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		if err := mergeFile(v, path, ""); err != nil {
+			return Config{}, err
+		}
+		break
+	}
+	defaultDir := filepath.Join(root, "config", "_default")
+	if entries, err := os.ReadDir(defaultDir); err == nil {
+		for _, e := range entries {
+			ext := filepath.Ext(e.Name())
+			if e.IsDir() || configType(ext) == "" {
+				continue
+			}
+			key := strings.TrimSuffix(e.Name(), ext)
+			if key == "hugo" || key == "config" {
+				key = ""
+			}
+			if err := mergeFile(v, filepath.Join(defaultDir, e.Name()), key); err != nil {
+				return Config{}, err
+			}
+		}
+	}
+
+	cfg := Config{Root: root}
+	cfg.FrontMatter = FrontMatter{
+		Date:        expand(v.GetStringSlice("frontmatter.date"), DefaultDate),
+		Lastmod:     expand(v.GetStringSlice("frontmatter.lastmod"), DefaultLastmod),
+		PublishDate: expand(v.GetStringSlice("frontmatter.publishdate"), DefaultPublishDate),
+		ExpiryDate:  expand(v.GetStringSlice("frontmatter.expirydate"), DefaultExpiryDate),
+	}
+
+	cfg.TimeZone = v.GetString("timezone")
+
+	cfg.DefaultLanguage = strings.ToLower(v.GetString("defaultcontentlanguage"))
+	if cfg.DefaultLanguage == "" {
+		cfg.DefaultLanguage = "en"
+	}
+	cfg.DefaultLanguageInSubdir = v.GetBool("defaultcontentlanguageinsubdir")
+	codes := make([]string, 0)
+	for code := range v.GetStringMap("languages") {
+		if code != cfg.DefaultLanguage {
+			codes = append(codes, code)
+		}
+	}
+	// The way Hugo orders languages: by weight, those without one last, then
+	// by code
+	weight := func(code string) int { return v.GetInt("languages." + code + ".weight") }
+	sort.Slice(codes, func(i, j int) bool {
+		wi, wj := weight(codes[i]), weight(codes[j])
+		if wi == wj {
+			return codes[i] < codes[j]
+		}
+		return wj == 0 || (wi != 0 && wi < wj)
+	})
+	for _, code := range append([]string{cfg.DefaultLanguage}, codes...) {
+		cfg.Languages = append(cfg.Languages, Language{
+			Code:       code,
+			ContentDir: v.GetString("languages." + code + ".contentdir"),
+		})
+	}
+
+	contentDir := v.GetString("contentdir")
+	if contentDir == "" {
+		contentDir = "content"
+	}
+	cfg.ContentRoots = contentRoots(root, contentDir, cfg.Languages, v.Get("module.mounts"))
+	return cfg, nil
+}
+
+// mergeFile merges a config file into v, under key when it is not empty.
+func mergeFile(v *viper.Viper, path, key string) error {
+	f := viper.New()
+	f.SetConfigFile(path)
+	f.SetConfigType(configType(filepath.Ext(path)))
+	if err := f.ReadInConfig(); err != nil {
+		return fmt.Errorf("read hugo config %s: %w", path, err)
+	}
+	settings := f.AllSettings()
+	if key != "" {
+		settings = map[string]any{key: settings}
+	}
+	return v.MergeConfigMap(settings)
+}
+
+func configType(ext string) string {
+	switch ext {
+	case ".toml":
+		return "toml"
+	case ".yaml", ".yml":
+		return "yaml"
+	case ".json":
+		return "json"
+	}
+	return ""
+}
+
+// expand lowercases the configured handlers, replaces ":default" with
+// Hugo's defaults and adds the aliases of every date field, the way Hugo
+// does. An empty configuration yields the defaults.
+func expand(configured, defaults []string) []string {
+	if len(configured) == 0 {
+		return defaults
+	}
+	var out []string
+	seen := map[string]bool{}
+	add := func(s string) {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	for _, h := range configured {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if h == ":default" {
+			for _, d := range defaults {
+				add(d)
+			}
+			continue
+		}
+		add(h)
+		for _, alias := range dateAliases[h] {
+			add(alias)
+		}
+	}
+	return out
+}
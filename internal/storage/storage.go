@@ -0,0 +1,89 @@
+// Package storage places the archived versions of pages: next to each
+// page, in a central tree inside the content directory, or in a directory
+// outside it that Hugo mounts into the content tree.
+package storage
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/ifeitao/hugo-revise/internal/config"
+	"github.com/ifeitao/hugo-revise/internal/content"
+	"github.com/ifeitao/hugo-revise/internal/site"
+)
+
+// New returns the layout configured in [storage].
+func New(cfg config.Storage, siteCfg site.Config) (content.Layout, error) {
+	switch cfg.Layout {
+	case "", "sibling":
+		return content.Sibling, nil
+	case "central":
+		if cfg.Dir == "" || filepath.IsAbs(cfg.Dir) {
+			return nil, fmt.Errorf("storage.layout = \"central\" needs storage.dir, a directory inside the content directory")
+		}
+		return centralLayout{siteCfg: siteCfg, dir: filepath.Clean(cfg.Dir)}, nil
+	case "mount":
+		if cfg.Dir == "" {
+			return nil, fmt.Errorf("storage.layout = \"mount\" needs storage.dir")
+		}
+		dir := cfg.Dir
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(siteCfg.Root, dir)
+		}
+		if abs, err := filepath.Abs(dir); err == nil {
+			dir = abs
+		}
+		for _, r := range siteCfg.ContentRoots {
+			if r.Dir == dir {
+				return mountLayout{siteCfg: siteCfg, dir: dir}, nil
+			}
+		}
+		return nil, fmt.Errorf(`storage.layout = "mount" needs %s mounted into the content tree; add to the Hugo config
+  [[module.mounts]]
+  source = %q
+  target = "content/revisions"
+and mount content itself as well, since Hugo drops its default mount then`, dir, cfg.Dir)
+	}
+	return nil, fmt.Errorf("unknown storage layout %q (want sibling, central or mount)", cfg.Layout)
+}
+
+// centralLayout keeps archives in one tree per content root,
+// content/revisions/<logical-path>/<label>, so that sections hold no
+// revisions directories of their own.
+type centralLayout struct {
+	siteCfg site.Config
+	dir     string // relative to the content root
+}
+
+func (l centralLayout) RevisionsDir(p content.Page) string {
+	_, root, ok := l.siteCfg.LogicalPath(p.Path)
+	if !ok || root.Dir == "" {
+		return content.Sibling.RevisionsDir(p)
+	}
+	abs, err := filepath.Abs(p.Path)
+	if err != nil {
+		return content.Sibling.RevisionsDir(p)
+	}
+	rel, err := filepath.Rel(root.Dir, abs)
+	if err != nil {
+		return content.Sibling.RevisionsDir(p)
+	}
+	return filepath.Join(root.Dir, l.dir, rel)
+}
+
+// mountLayout keeps archives outside the content directory, in
+// <dir>/<logical-path>/<label>, where dir is mounted into the content
+// tree. Pages of a language with its own content directory are kept under
+// <dir>/<language>, as their paths would clash otherwise.
+type mountLayout struct {
+	siteCfg site.Config
+	dir     string // absolute
+}
+
+func (l mountLayout) RevisionsDir(p content.Page) string {
+	logical, root, ok := l.siteCfg.LogicalPath(p.Path)
+	if !ok {
+		return content.Sibling.RevisionsDir(p)
+	}
+	return filepath.Join(l.dir, root.Lang, filepath.FromSlash(logical))
+}
@@ -6,10 +6,15 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/ifeitao/hugo-revise/internal/config"
+	"github.com/ifeitao/hugo-revise/internal/content"
 	"github.com/ifeitao/hugo-revise/internal/fm"
+	"github.com/ifeitao/hugo-revise/internal/label"
+	"github.com/ifeitao/hugo-revise/internal/site"
+	"github.com/ifeitao/hugo-revise/internal/storage"
 )
 
 type change struct {
@@ -19,8 +24,11 @@ type change struct {
 }
 
 type lastOp struct {
-	OriginalContent string   `json:"original_content"`
-	Changes         []change `json:"changes"`
+	OriginalContent string            `json:"original_content"` // single-page logs from older versions
+	Command         string            `json:"command"`
+	OnCollision     string            `json:"on_collision"`
+	Originals       map[string]string `json:"originals"`
+	Changes         []change          `json:"changes"`
 }
 
 func Run(cfg config.Config) error {
@@ -34,28 +42,59 @@ func Run(cfg config.Config) error {
 		return err
 	}
 
-	// Find the source file and archived target from changes
-	var sourceFile string
-	var archivedTarget string
+	// Find the source files, archived targets, overwritten archives and
+	// moved archives from changes. An amending revision archives nothing.
+	var sourceFiles []string
+	var archivedTargets []string
+	var restores []change
+	var moves []change
+	var manifests []string
 	for _, c := range op.Changes {
-		if c.Action == "write" {
-			sourceFile = c.Source
-		}
-		if c.Action == "copy" {
-			archivedTarget = c.Target
+		switch c.Action {
+		case "write":
+			sourceFiles = append(sourceFiles, c.Source)
+		case "copy":
+			archivedTargets = append(archivedTargets, c.Target)
+		case "restore":
+			restores = append(restores, c)
+		case "move":
+			moves = append(moves, c)
+		case "manifest":
+			manifests = append(manifests, c.Target)
 		}
 	}
 
-	if sourceFile == "" || archivedTarget == "" {
+	// Revisions archive something unless they amend; relabels and updates
+	// log every file they wrote, and a rebuilt manifest is all there is
+	if (len(sourceFiles) == 0 && op.Command != "manifest") || (len(archivedTargets) == 0 && op.OnCollision != "amend" && op.Command == "") {
 		return errors.New("invalid operation log: missing source or target")
 	}
 
+	// Move relabelled archives back first: their files are restored under
+	// the old paths
+	for i := len(moves) - 1; i >= 0; i-- {
+		if err := os.Rename(moves[i].Target, moves[i].Source); err != nil {
+			return fmt.Errorf("failed to move relabelled archive back: %w", err)
+		}
+	}
+
+	strategy, err := label.New(cfg.Versioning)
+	if err != nil {
+		return err
+	}
+
 	// Restore original source file content
-	if op.OriginalContent != "" {
-		if err := os.WriteFile(sourceFile, []byte(op.OriginalContent), 0o644); err != nil {
-			return fmt.Errorf("failed to restore source file: %w", err)
+	for _, sourceFile := range sourceFiles {
+		original, ok := op.Originals[sourceFile]
+		if !ok && len(sourceFiles) == 1 && op.OriginalContent != "" {
+			original, ok = op.OriginalContent, true
+		}
+		if ok {
+			if err := os.WriteFile(sourceFile, []byte(original), 0o644); err != nil {
+				return fmt.Errorf("failed to restore source file: %w", err)
+			}
+			continue
 		}
-	} else {
 		// Fallback: try to update revisions_history by removing the last version
 		// This handles undo for operations logged before the OriginalContent field was added
 		data, err := os.ReadFile(sourceFile)
@@ -64,7 +103,8 @@ func Run(cfg config.Config) error {
 			if err == nil {
 				history := fm.GetList(parsed, "revisions_history")
 				if len(history) > 1 {
-					// Remove the last version (current revision being undone)
+					// Remove the newest version (current revision being undone)
+					label.Sort(strategy, history)
 					history = history[:len(history)-1]
 					parsed, _ = fm.InjectList(parsed, "revisions_history", history)
 					_ = os.WriteFile(sourceFile, []byte(fm.Stringify(parsed)), 0o644)
@@ -73,70 +113,182 @@ func Run(cfg config.Config) error {
 		}
 	}
 
-	// Remove the archived version directory/file
-	if err := os.RemoveAll(archivedTarget); err != nil {
-		return fmt.Errorf("failed to remove archived version: %w", err)
+	// Put back the manifests of the revisions directories; one made by the
+	// operation is removed
+	for _, path := range manifests {
+		if original, ok := op.Originals[path]; ok {
+			if err := os.WriteFile(path, []byte(original), 0o644); err != nil {
+				return fmt.Errorf("failed to restore manifest: %w", err)
+			}
+		} else if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove manifest: %w", err)
+		}
 	}
 
-	// Update all remaining archived versions' revisions_history
-	// Determine revisions directory
-	revisionsDir := ""
-	if strings.HasSuffix(sourceFile, "index.md") {
-		// Bundle: revisions dir is at parent/../bundlename.revisions/
-		bundleDir := filepath.Dir(sourceFile)
-		bundleName := filepath.Base(bundleDir)
-		revisionsDir = filepath.Join(filepath.Dir(bundleDir), bundleName+".revisions")
-	} else {
-		// Single file: revisions dir is at same level
-		baseName := strings.TrimSuffix(filepath.Base(sourceFile), ".md")
-		revisionsDir = filepath.Join(filepath.Dir(sourceFile), baseName+".revisions")
+	// Remove the archived version directories/files, and the directories
+	// that held nothing else
+	for _, archivedTarget := range archivedTargets {
+		if err := os.RemoveAll(archivedTarget); err != nil {
+			return fmt.Errorf("failed to remove archived version: %w", err)
+		}
+		removeEmptyDirs(filepath.Dir(archivedTarget))
 	}
 
-	if _, err := os.Stat(revisionsDir); err == nil {
+	// Put back the archives an overwriting revision replaced
+	for _, r := range restores {
+		if err := os.RemoveAll(r.Target); err != nil {
+			return fmt.Errorf("failed to remove overwritten archive: %w", err)
+		}
+		if err := os.Rename(r.Source, r.Target); err != nil {
+			return fmt.Errorf("failed to restore overwritten archive: %w", err)
+		}
+	}
+
+	// Update all remaining archived versions' revisions_history. Restoring
+	// the files a relabel or update wrote is enough.
+	repropagate := sourceFiles
+	if op.Command != "" {
+		repropagate = nil
+	}
+	for _, sourceFile := range repropagate {
+		siteCfg, err := site.Discover(filepath.Dir(sourceFile))
+		if err != nil {
+			return err
+		}
+		layout, err := storage.New(cfg.Storage, siteCfg)
+		if err != nil {
+			return err
+		}
+		page := content.FromSource(sourceFile, siteCfg.LanguageCodes()).WithLayout(layout)
+		if _, err := os.Stat(page.RevisionsDir()); err != nil {
+			continue
+		}
 		// Get updated revisions_history from restored source file
 		data, err := os.ReadFile(sourceFile)
-		if err == nil {
-			parsed, err := fm.Parse(string(data))
-			if err == nil {
-				history := fm.GetList(parsed, "revisions_history")
-				// Update all archived versions with the corrected history
-				entries, _ := os.ReadDir(revisionsDir)
-				for _, e := range entries {
-					name := e.Name()
-					var targetPath string
-					if e.IsDir() {
-						// Bundle archived version
-						targetPath = filepath.Join(revisionsDir, name, "index.md")
-					} else if strings.HasSuffix(name, ".md") {
-						// Single file archived version
-						targetPath = filepath.Join(revisionsDir, name)
-					} else {
-						continue
-					}
-
-					if _, err := os.Stat(targetPath); err != nil {
-						continue
-					}
-
-					data, err := os.ReadFile(targetPath)
-					if err != nil {
-						continue
-					}
-					fmParsed, err := fm.Parse(string(data))
-					if err != nil {
-						continue
-					}
-					fmParsed, _ = fm.InjectList(fmParsed, "revisions_history", history)
-					_ = os.WriteFile(targetPath, []byte(fm.Stringify(fmParsed)), 0o644)
+		if err != nil {
+			continue
+		}
+		parsed, err := fm.Parse(string(data))
+		if err != nil {
+			continue
+		}
+		history := fm.GetList(parsed, "revisions_history")
+		// Update all archived versions with the corrected history, notes,
+		// authors and structured revisions list
+		for _, a := range page.Archives() {
+			if a.File == "" {
+				continue
+			}
+			targetPath := a.File
+
+			data, err := os.ReadFile(targetPath)
+			if err != nil {
+				continue
+			}
+			fmParsed, err := fm.Parse(string(data))
+			if err != nil {
+				continue
+			}
+			fmParsed, _ = fm.InjectList(fmParsed, "revisions_history", history)
+			for _, key := range []string{"revisions_notes", "revisions_authors", "revisions"} {
+				if parsed.Format == fm.Org {
+					// An Org page keeps no maps, so the archives keep
+					// theirs, less the versions undone
+					fmParsed = keepVersions(fmParsed, key, history)
+				} else if v, ok := fm.Get(parsed, key); ok {
+					fmParsed, _ = fm.Set(fmParsed, key, v)
+				} else {
+					fmParsed, _ = fm.RemoveKey(fmParsed, key)
 				}
 			}
+			_ = os.WriteFile(targetPath, []byte(fm.Stringify(fmParsed)), 0o644)
 		}
 	}
 
-	// Remove log file
+	// Remove log file and what is left of the overwritten archives
+	_ = os.RemoveAll(filepath.Join(config.LogDirectory, "overwritten"))
 	if err := os.Remove(logPath); err != nil {
 		return fmt.Errorf("failed to remove log file: %w", err)
 	}
 
 	return nil
 }
+
+// removeEmptyDirs removes dir and its parents while they are empty, up to
+// but not including a content root or the site root, so that undoing a
+// page's first revision leaves no empty revisions directories behind.
+func removeEmptyDirs(dir string) {
+	siteCfg, err := site.Discover(dir)
+	if err != nil {
+		return
+	}
+	stop := map[string]bool{}
+	for _, r := range siteCfg.ContentRoots {
+		stop[r.Dir] = true
+	}
+	if siteCfg.Root != "" {
+		if root, err := filepath.Abs(siteCfg.Root); err == nil {
+			stop[root] = true
+		}
+	}
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return
+	}
+	for !stop[dir] && filepath.Dir(dir) != dir {
+		if os.Remove(dir) != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// keepVersions drops from the map or list at key in f the versions not in
+// history: the keys of a map, the entries of a list by their label.
+func keepVersions(f fm.FrontMatter, key string, history []string) fm.FrontMatter {
+	v, ok := fm.Get(f, key)
+	if !ok {
+		return f
+	}
+	kept := func(l string) bool {
+		return slices.ContainsFunc(history, func(h string) bool { return strings.EqualFold(h, l) })
+	}
+	var out any
+	n := 0
+	if v.Kind == fm.ListKind {
+		// The current version undone hands its place to the last one kept
+		var entries []map[string]any
+		var current any
+		for _, e := range v.List() {
+			m, _ := e.Interface().(map[string]any)
+			if kept(e.Map()["label"].String()) {
+				entries = append(entries, m)
+			} else if b, _ := e.Map()["current"].Bool(); b {
+				current = m["url"]
+			}
+		}
+		if current != nil && len(entries) > 0 {
+			entries[len(entries)-1]["current"] = true
+			entries[len(entries)-1]["url"] = current
+		}
+		list := make([]any, len(entries))
+		for i, e := range entries {
+			list[i] = e
+		}
+		out, n = list, len(list)
+	} else {
+		values := map[string]any{}
+		for l, e := range v.Map() {
+			if kept(l) {
+				values[l] = e.Interface()
+			}
+		}
+		out, n = values, len(values)
+	}
+	if n == 0 {
+		f, _ = fm.RemoveKey(f, key)
+	} else {
+		f, _ = fm.Set(f, key, out)
+	}
+	return f
+}